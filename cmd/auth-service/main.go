@@ -10,7 +10,9 @@ import (
 	"auth-service/internal/httpserver"
 	"auth-service/internal/httpserver/handler"
 	"auth-service/internal/httpserver/handler/middleware/auth"
+	"auth-service/internal/httpserver/handler/middleware/client"
 	"auth-service/internal/httpserver/handler/middleware/ip"
+	"auth-service/internal/httpserver/handler/middleware/issuance"
 	"auth-service/internal/repository"
 	"auth-service/internal/service"
 	"auth-service/pkg/logger"
@@ -24,6 +26,7 @@ import (
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
+// @securityDefinitions.basic BasicAuth
 
 func main() {
 	cfg, err := config.NewConfig()
@@ -40,18 +43,33 @@ func main() {
 	}
 	zap.S().Info("repository initialized")
 
-	svc := service.NewService(repo, cfg.ServiceConfig)
+	svc, err := service.NewService(repo, cfg.ServiceConfig)
+	if err != nil {
+		zap.S().Fatalf("failed to initialize service: %s", err)
+	}
 	zap.S().Info("service initialized")
 
 	// ToDO: swagger описать и docker-compose, посмотреть как что с логированием у нас
 	h := handler.NewHandler(svc)
-	authMiddleware := auth.Middleware(svc.GetCurrentUserID)
+	authMiddleware := auth.Middleware(svc.ValidateAccessToken, auth.WithDPoP(svc.VerifyDPoPProof))
 	ipMiddleware := ip.Middleware
+	clientMiddleware := client.Middleware(svc.AuthenticateIntrospectionClient)
+	revocationMiddleware := client.Middleware(svc.AuthenticateRevocationClient)
+	issuanceMiddleware := issuance.Middleware(svc.IssuanceDevMode(),
+		issuance.ClientSecret(svc.AuthenticateIssuanceClient),
+		issuance.SignedAssertion(svc.VerifyIssuanceAssertion),
+	)
+	if svc.IssuanceDevMode() {
+		zap.S().Warn("ISSUANCE_DEV_MODE is enabled: tokens are issued without authenticating the caller")
+	}
 
-	server := httpserver.CreateServer(cfg.ServerConfig, h, authMiddleware, ipMiddleware)
+	server, err := httpserver.CreateServer(cfg.ServerConfig, h, authMiddleware, ipMiddleware, clientMiddleware, issuanceMiddleware, revocationMiddleware)
+	if err != nil {
+		zap.S().Fatalf("failed to create server: %s", err)
+	}
 
-	zap.S().Infof("starting server on %s", cfg.ServerConfig.Port)
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	zap.S().Infof("starting server on %s (tls: %t)", cfg.ServerConfig.Port, cfg.ServerConfig.TLSEnabled())
+	if err := httpserver.Serve(server, cfg.ServerConfig); err != nil && err != http.ErrServerClosed {
 		zap.S().Fatalf("server failed: %v", err)
 	}
 }
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -9,8 +10,11 @@ import (
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 
+	"auth-service/internal/models"
 	"auth-service/internal/service"
+	"auth-service/pkg/dpop"
 	"auth-service/pkg/er"
+	"auth-service/pkg/mtls"
 )
 
 type Handler struct {
@@ -23,14 +27,22 @@ func NewHandler(svc *service.Service) *Handler {
 
 // GenerateTokens
 // @Summary      Генерация access и refresh токенов
-// @Description  Генерирует пару токенов по guid пользователя
+// @Description  Генерирует пару токенов по guid пользователя. С заголовком DPoP токены привязываются к ключу клиента (RFC 9449).
+// @Description  Вызывающий аутентифицируется как доверенный клиент со scope tokens:issue (HTTP Basic)
+// @Description  или подписанным утверждением identity front-end (Authorization: Bearer), кроме режима ISSUANCE_DEV_MODE.
 // @Tags         auth
 // @Param        guid path string true "GUID пользователя"
-// @Success      200 {object} Response
-// @Failure      400 {object} Response "guid не передан или неверный формат"
+// @Param        DPoP header string false "DPoP proof"
+// @Success      200 {object} Response{data=TokenPair}
+// @Failure      400 {object} Response "guid не передан или неверный формат, либо неверный DPoP proof"
+// @Failure      401 {object} Response "Вызывающий не аутентифицирован"
+// @Failure      403 {object} Response "Пользователь заблокирован"
 // @Failure      404 {object} Response "Пользователь не найден"
+// @Failure      409 {object} Response "Достигнут MAX_SESSIONS_PER_USER при политике reject"
 // @Failure      500 {object} Response "Внутренняя ошибка сервера"
 // @Router       /tokens/{guid} [post]
+// @Security     BasicAuth
+// @Security     BearerAuth
 func (h *Handler) GenerateTokens() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		zap.S().Infof("GenerateTokens handler start")
@@ -69,7 +81,17 @@ func (h *Handler) GenerateTokens() http.HandlerFunc {
 			return
 		}
 
-		at, rt, err := h.svc.GenerateTokens(r.Context(), guid, userAgent, ip)
+		dpopJKT, err := h.dpopThumbprint(r)
+		if err != nil {
+			WriteJSONResponse(w, http.StatusBadRequest, Response{
+				Status: "error",
+				Msg:    "invalid dpop proof",
+			})
+			zap.S().Warnf("GenerateTokens handler error: invalid dpop proof")
+			return
+		}
+
+		pair, err := h.svc.GenerateTokens(r.Context(), guid, userAgent, ip, dpopJKT)
 		if err != nil {
 			if errors.Is(err, er.ErrNotFound) {
 				zap.S().Infof("user not found: %v", err)
@@ -81,6 +103,22 @@ func (h *Handler) GenerateTokens() http.HandlerFunc {
 				zap.S().Warnf("GenerateTokens handler error: user not found")
 				return
 			}
+			if errors.Is(err, er.ErrUserDisabled) {
+				WriteJSONResponse(w, http.StatusForbidden, Response{
+					Status: "error",
+					Msg:    "user is disabled",
+				})
+				zap.S().Warnf("GenerateTokens handler error: user %s is disabled", guid)
+				return
+			}
+			if errors.Is(err, er.ErrTooManySessions) {
+				WriteJSONResponse(w, http.StatusConflict, Response{
+					Status: "error",
+					Msg:    "too many active sessions, revoke one of them first",
+				})
+				zap.S().Warnf("GenerateTokens handler error: session limit reached for user %s", guid)
+				return
+			}
 			zap.S().Errorf("failed to generate tokens: %v", err)
 			WriteJSONResponse(w, http.StatusInternalServerError, Response{
 				Status: "error",
@@ -92,8 +130,10 @@ func (h *Handler) GenerateTokens() http.HandlerFunc {
 		}
 
 		data := TokenPair{
-			AccessToken:  at,
-			RefreshToken: rt,
+			AccessToken:  pair.AccessToken,
+			RefreshToken: pair.RefreshToken,
+			IDToken:      pair.IDToken,
+			TokenType:    pair.TokenType,
 		}
 
 		WriteJSONResponse(w, http.StatusOK, Response{
@@ -107,14 +147,17 @@ func (h *Handler) GenerateTokens() http.HandlerFunc {
 
 // RefreshTokens
 // @Summary      Обновление access и refresh токенов
-// @Description  Обновляет пару токенов по refresh токену
+// @Description  Обновляет пару токенов. Access и refresh токены должны быть выпущены одной парой.
+// @Description  Истёкший access токен принимается в течение ACCESS_EXPIRED_GRACE после окончания срока действия.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        body body RefreshTokensRequest true "Тело запроса"
-// @Success      200 {object} Response
-// @Failure      400 {object} Response "Некорректное тело запроса"
-// @Failure      401 {object} Response "Неверный access или refresh токен"
+// @Param        DPoP header string false "DPoP proof, обязателен для сессий, привязанных к DPoP ключу"
+// @Success      200 {object} Response{data=TokenPair}
+// @Failure      400 {object} Response "Некорректное тело запроса или DPoP proof"
+// @Failure      401 {object} Response "Неверный или истёкший access или refresh токен, токены не из одной пары, повторное использование refresh токена, либо proof другого ключа"
+// @Failure      403 {object} Response "Пользователь заблокирован"
 // @Failure      404 {object} Response "Пользователь не найден"
 // @Failure      500 {object} Response "Внутренняя ошибка сервера"
 // @Router       /tokens/refresh [post]
@@ -135,19 +178,26 @@ func (h *Handler) RefreshTokens() http.HandlerFunc {
 		ipVal := r.Context().Value(ContextKeyIP)
 		ip, _ := ipVal.(string)
 
-		userID, err := h.svc.GetCurrentUserID(req.AccessToken)
+		dpopJKT, err := h.dpopThumbprint(r)
 		if err != nil {
-			zap.S().Errorf("invalid access token in refresh: %v", err)
-			WriteJSONResponse(w, http.StatusUnauthorized, Response{
+			WriteJSONResponse(w, http.StatusBadRequest, Response{
 				Status: "error",
-				Msg:    "invalid access token",
+				Msg:    "invalid dpop proof",
 			})
-			zap.S().Errorf("RefreshTokens handler error: invalid access token")
+			zap.S().Warnf("RefreshTokens handler error: invalid dpop proof")
 			return
 		}
 
-		at, rt, err := h.svc.RefreshTokens(r.Context(), userID, req.RefreshToken, userAgent, ip)
+		pair, err := h.svc.RefreshTokens(r.Context(), req.AccessToken, req.RefreshToken, userAgent, ip, dpopJKT)
 		if err != nil {
+			if errors.Is(err, er.ErrInvalidDPoPProof) {
+				WriteJSONResponse(w, http.StatusUnauthorized, Response{
+					Status: "error",
+					Msg:    "session is bound to another dpop key",
+				})
+				zap.S().Warnf("RefreshTokens handler error: dpop key mismatch")
+				return
+			}
 			if errors.Is(err, er.ErrNotFound) {
 				WriteJSONResponse(w, http.StatusNotFound, Response{
 					Status: "error",
@@ -156,12 +206,36 @@ func (h *Handler) RefreshTokens() http.HandlerFunc {
 				zap.S().Warnf("RefreshTokens handler error: user not found")
 				return
 			}
+			if errors.Is(err, er.ErrUserDisabled) {
+				WriteJSONResponse(w, http.StatusForbidden, Response{
+					Status: "error",
+					Msg:    "user is disabled",
+				})
+				zap.S().Warnf("RefreshTokens handler error: user is disabled")
+				return
+			}
 			if errors.Is(err, er.ErrInvalidToken) {
 				WriteJSONResponse(w, http.StatusUnauthorized, Response{
 					Status: "error",
-					Msg:    "invalid refresh token",
+					Msg:    "invalid access or refresh token",
 				})
-				zap.S().Warnf("RefreshTokens handler error: invalid refresh token")
+				zap.S().Warnf("RefreshTokens handler error: invalid access or refresh token")
+				return
+			}
+			if errors.Is(err, er.ErrTokenExpired) {
+				WriteJSONResponse(w, http.StatusUnauthorized, Response{
+					Status: "error",
+					Msg:    "token expired, log in again",
+				})
+				zap.S().Warnf("RefreshTokens handler error: token expired")
+				return
+			}
+			if errors.Is(err, er.ErrTokenReuse) {
+				WriteJSONResponse(w, http.StatusUnauthorized, Response{
+					Status: "error",
+					Msg:    "refresh token reuse detected, session revoked",
+				})
+				zap.S().Warnf("RefreshTokens handler error: refresh token reuse detected")
 				return
 			}
 			if errors.Is(err, er.ErrUserAgentMismatch) {
@@ -183,18 +257,183 @@ func (h *Handler) RefreshTokens() http.HandlerFunc {
 
 		WriteJSONResponse(w, http.StatusOK, Response{
 			Status: "ok",
-			Data:   TokenPair{AccessToken: at, RefreshToken: rt},
+			Data:   TokenPair{AccessToken: pair.AccessToken, RefreshToken: pair.RefreshToken, IDToken: pair.IDToken, TokenType: pair.TokenType},
 		})
 		zap.S().Infof("RefreshTokens handler success")
 	}
 }
 
+// ListSessions
+// @Summary      Список активных сессий
+// @Description  Возвращает активные сессии текущего пользователя: User-Agent и разобранное из него устройство, IP, время входа,
+// @Description  последнего использования и окончания сессии, число обновлений токенов и историю адресов.
+// @Description  Сессия, из которой сделан запрос, отмечена current.
+// @Tags         sessions
+// @Produce      json
+// @Success      200 {object} Response{data=[]service.Session}
+// @Failure      401 {object} Response "Отсутствует или неверный access токен, либо токен выдан не пользователю"
+// @Failure      403 {object} Response "В access токене нет scope sessions:read"
+// @Failure      500 {object} Response "Внутренняя ошибка сервера"
+// @Router       /me/sessions [get]
+// @Security     BearerAuth
+func (h *Handler) ListSessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zap.S().Infof("ListSessions handler start")
+		userID, claims, ok := sessionOwner(r)
+		if !ok {
+			WriteJSONResponse(w, http.StatusUnauthorized, Response{
+				Status: "error",
+				Msg:    "access token is not issued to a user",
+			})
+			zap.S().Warnf("ListSessions handler error: access token is not issued to a user")
+			return
+		}
+		sessions, err := h.svc.ListSessions(r.Context(), userID, claims.SessionID)
+		if err != nil {
+			zap.S().Errorf("failed to list sessions: %v", err)
+			WriteJSONResponse(w, http.StatusInternalServerError, Response{
+				Status: "error",
+				Msg:    "internal server error",
+			})
+			zap.S().Errorf("ListSessions handler error: failed to list sessions")
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		WriteJSONResponse(w, http.StatusOK, Response{
+			Status: "ok",
+			Data:   sessions,
+		})
+		zap.S().Infof("ListSessions handler success")
+	}
+}
+
+// RevokeSession
+// @Summary      Завершение сессии
+// @Description  Завершает одну сессию текущего пользователя: инвалидирует её refresh токены и отзывает access токен.
+// @Description  Можно завершить и текущую сессию. Чужая или уже завершённая сессия даёт 404.
+// @Tags         sessions
+// @Produce      json
+// @Param        id path string true "Идентификатор сессии (sid)"
+// @Success      200 {object} Response
+// @Failure      400 {object} Response "Неверный формат идентификатора сессии"
+// @Failure      401 {object} Response "Отсутствует или неверный access токен, либо токен выдан не пользователю"
+// @Failure      403 {object} Response "В access токене нет scope sessions:write"
+// @Failure      404 {object} Response "У пользователя нет такой активной сессии"
+// @Failure      500 {object} Response "Внутренняя ошибка сервера"
+// @Router       /me/sessions/{id} [delete]
+// @Security     BearerAuth
+func (h *Handler) RevokeSession() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zap.S().Infof("RevokeSession handler start")
+		userID, _, ok := sessionOwner(r)
+		if !ok {
+			WriteJSONResponse(w, http.StatusUnauthorized, Response{
+				Status: "error",
+				Msg:    "access token is not issued to a user",
+			})
+			zap.S().Warnf("RevokeSession handler error: access token is not issued to a user")
+			return
+		}
+		sessionID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			WriteJSONResponse(w, http.StatusBadRequest, Response{
+				Status: "error",
+				Msg:    "invalid session id format",
+			})
+			zap.S().Warnf("RevokeSession handler error: invalid session id format")
+			return
+		}
+		if err := h.svc.RevokeSession(r.Context(), userID, sessionID); err != nil {
+			if errors.Is(err, er.ErrNotFound) {
+				WriteJSONResponse(w, http.StatusNotFound, Response{
+					Status: "error",
+					Msg:    "session not found",
+				})
+				zap.S().Warnf("RevokeSession handler error: session %s not found for user %s", sessionID, userID)
+				return
+			}
+			zap.S().Errorf("failed to revoke session: %v", err)
+			WriteJSONResponse(w, http.StatusInternalServerError, Response{
+				Status: "error",
+				Msg:    "internal server error",
+			})
+			zap.S().Errorf("RevokeSession handler error: failed to revoke session")
+			return
+		}
+		WriteJSONResponse(w, http.StatusOK, Response{
+			Status: "ok",
+			Msg:    "session revoked",
+		})
+		zap.S().Infof("RevokeSession handler success")
+	}
+}
+
+// RevokeOtherSessions
+// @Summary      Завершение остальных сессий
+// @Description  Завершает все сессии текущего пользователя, кроме той, из которой сделан запрос.
+// @Tags         sessions
+// @Produce      json
+// @Success      200 {object} Response{data=RevokeOtherSessionsResponse}
+// @Failure      401 {object} Response "Отсутствует или неверный access токен, либо токен выдан не пользователю"
+// @Failure      403 {object} Response "В access токене нет scope sessions:write"
+// @Failure      500 {object} Response "Внутренняя ошибка сервера"
+// @Router       /me/sessions/revoke-others [post]
+// @Security     BearerAuth
+func (h *Handler) RevokeOtherSessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zap.S().Infof("RevokeOtherSessions handler start")
+		userID, claims, ok := sessionOwner(r)
+		if !ok || claims.SessionID == uuid.Nil {
+			WriteJSONResponse(w, http.StatusUnauthorized, Response{
+				Status: "error",
+				Msg:    "access token is not issued to a user",
+			})
+			zap.S().Warnf("RevokeOtherSessions handler error: access token is not issued to a user")
+			return
+		}
+		revoked, err := h.svc.RevokeOtherSessions(r.Context(), userID, claims.SessionID)
+		if err != nil {
+			zap.S().Errorf("failed to revoke other sessions: %v", err)
+			WriteJSONResponse(w, http.StatusInternalServerError, Response{
+				Status: "error",
+				Msg:    "internal server error",
+			})
+			zap.S().Errorf("RevokeOtherSessions handler error: failed to revoke other sessions")
+			return
+		}
+		WriteJSONResponse(w, http.StatusOK, Response{
+			Status: "ok",
+			Data:   RevokeOtherSessionsResponse{Revoked: revoked},
+		})
+		zap.S().Infof("RevokeOtherSessions handler success")
+	}
+}
+
+// sessionOwner возвращает пользователя и claims access токена, положенные в контекст auth.Middleware
+func sessionOwner(r *http.Request) (uuid.UUID, *models.AccessTokenClaims, bool) {
+	claims, ok := r.Context().Value(ContextKeyClaims).(*models.AccessTokenClaims)
+	if !ok || claims.UserID == uuid.Nil {
+		return uuid.Nil, nil, false
+	}
+	return claims.UserID, claims, true
+}
+
+// dpopThumbprint проверяет необязательный DPoP proof запроса выдачи токенов.
+// Без заголовка DPoP возвращает пустой отпечаток: токены выдаются как bearer.
+func (h *Handler) dpopThumbprint(r *http.Request) (string, error) {
+	proof := r.Header.Get(dpop.HeaderName)
+	if proof == "" {
+		return "", nil
+	}
+	return h.svc.VerifyDPoPProof(r.Context(), proof, r.Method, r.URL.Path, "")
+}
+
 // GetMe
 // @Summary      Получить информацию о себе
 // @Description  Возвращает GUID текущего пользователя по access токену
 // @Tags         auth
 // @Produce      json
-// @Success      200 {object} Response
+// @Success      200 {object} Response{data=MeResponse}
 // @Failure      401 {object} Response "Отсутствует или неверный access токен"
 // @Router       /me [get]
 // @Security     BearerAuth
@@ -212,7 +451,7 @@ func (h *Handler) GetMe() http.HandlerFunc {
 			zap.S().Warnf("GetMe handler error: missing or invalid access token")
 			return
 		}
-		userID, err := h.svc.GetCurrentUserID(accessToken)
+		userID, err := h.svc.GetCurrentUserID(r.Context(), accessToken)
 		if err != nil {
 			zap.S().Warnf("invalid access token: %v", err)
 			WriteJSONResponse(w, http.StatusUnauthorized, Response{
@@ -232,7 +471,7 @@ func (h *Handler) GetMe() http.HandlerFunc {
 
 // Logout
 // @Summary      Выход пользователя
-// @Description  Инвалидирует access токен пользователя
+// @Description  Отзывает access токены и инвалидирует все refresh токены пользователя
 // @Tags         auth
 // @Produce      json
 // @Success      200 {object} Response "Успешный выход"
@@ -279,3 +518,794 @@ func (h *Handler) Logout() http.HandlerFunc {
 		zap.S().Infof("Logout handler success")
 	}
 }
+
+// JWKS отдаёт публичные ключи подписи access токенов в формате JWK Set.
+// Маршрут /.well-known/jwks.json находится вне BasePath /api, поэтому в Swagger не описан.
+func (h *Handler) JWKS() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		WriteJSON(w, http.StatusOK, h.svc.JWKS())
+	}
+}
+
+// OpenIDConfiguration отдаёт документ OpenID Connect Discovery.
+// Маршрут /.well-known/openid-configuration находится вне BasePath /api, поэтому в Swagger не описан.
+func (h *Handler) OpenIDConfiguration() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		WriteJSON(w, http.StatusOK, h.svc.OpenIDConfiguration())
+	}
+}
+
+// UserInfo
+// @Summary      OpenID Connect userinfo
+// @Description  Возвращает claims пользователя, которому выдан access токен
+// @Tags         oidc
+// @Produce      json
+// @Success      200 {object} service.UserInfo
+// @Failure      401 {object} Response "Отсутствует или неверный access токен, либо токен выдан не пользователю"
+// @Failure      403 {object} Response "В access токене нет scope openid"
+// @Failure      404 {object} Response "Пользователь не найден"
+// @Failure      500 {object} Response "Внутренняя ошибка сервера"
+// @Router       /userinfo [get]
+// @Router       /userinfo [post]
+// @Security     BearerAuth
+func (h *Handler) UserInfo() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zap.S().Infof("UserInfo handler start")
+		guid, _ := r.Context().Value(ContextKeyGUID).(string)
+		userID, err := uuid.Parse(guid)
+		if err != nil {
+			WriteJSONResponse(w, http.StatusUnauthorized, Response{
+				Status: "error",
+				Msg:    "access token is not issued to a user",
+			})
+			zap.S().Warnf("UserInfo handler error: access token is not issued to a user")
+			return
+		}
+		info, err := h.svc.UserInfo(r.Context(), userID)
+		if err != nil {
+			if errors.Is(err, er.ErrNotFound) {
+				WriteJSONResponse(w, http.StatusNotFound, Response{
+					Status: "error",
+					Msg:    "user not found",
+				})
+				zap.S().Warnf("UserInfo handler error: user not found")
+				return
+			}
+			zap.S().Errorf("failed to get user info: %v", err)
+			WriteJSONResponse(w, http.StatusInternalServerError, Response{
+				Status: "error",
+				Msg:    "internal server error",
+			})
+			zap.S().Errorf("UserInfo handler error: failed to get user info")
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		WriteJSON(w, http.StatusOK, info)
+		zap.S().Infof("UserInfo handler success")
+	}
+}
+
+// Introspect
+// @Summary      Интроспекция токена (RFC 7662)
+// @Description  Возвращает состояние access или refresh токена. Требует аутентификации вызывающего сервиса.
+// @Description  Для недействительного токена возвращается {"active": false}.
+// @Tags         oauth
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Param        token           formData string true  "Access или refresh токен"
+// @Param        token_type_hint formData string false "Подсказка: access_token или refresh_token"
+// @Success      200 {object} service.Introspection
+// @Failure      400 {object} Response "Токен не передан"
+// @Failure      401 {object} Response "Неверные учётные данные клиента"
+// @Failure      500 {object} Response "Внутренняя ошибка сервера"
+// @Router       /introspect [post]
+// @Security     BasicAuth
+func (h *Handler) Introspect() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zap.S().Infof("Introspect handler start")
+		if err := r.ParseForm(); err != nil || r.PostFormValue("token") == "" {
+			WriteJSONResponse(w, http.StatusBadRequest, Response{
+				Status: "error",
+				Msg:    "token is required",
+			})
+			zap.S().Warnf("Introspect handler error: token is required")
+			return
+		}
+
+		result, err := h.svc.Introspect(r.Context(), r.PostFormValue("token"), r.PostFormValue("token_type_hint"))
+		if err != nil {
+			zap.S().Errorf("failed to introspect token: %v", err)
+			WriteJSONResponse(w, http.StatusInternalServerError, Response{
+				Status: "error",
+				Msg:    "internal server error",
+			})
+			zap.S().Errorf("Introspect handler error: failed to introspect token")
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		WriteJSON(w, http.StatusOK, result)
+		zap.S().Infof("Introspect handler success")
+	}
+}
+
+// Revoke
+// @Summary      Отзыв токена (RFC 7009)
+// @Description  Отзывает один access или refresh токен, выданный вызывающему клиенту. Отзыв refresh токена завершает только его сессию.
+// @Description  Конфиденциальный клиент аутентифицируется через HTTP Basic или параметры client_id и client_secret,
+// @Description  публичный клиент передаёт только client_id.
+// @Description  Истёкший access токен, которым ещё можно обновить пару (ACCESS_EXPIRED_GRACE), тоже отзывается.
+// @Description  Для неизвестного, уже недействительного или выданного другому клиенту токена также возвращается 200.
+// @Tags         oauth
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Param        token           formData string true  "Access или refresh токен"
+// @Param        token_type_hint formData string false "Подсказка: access_token или refresh_token"
+// @Param        client_id       formData string false "client_id, если не передан через HTTP Basic"
+// @Param        client_secret   formData string false "Секрет клиента, если не передан через HTTP Basic"
+// @Success      200 {object} Response
+// @Failure      400 {object} Response "Токен не передан"
+// @Failure      401 {object} Response "Неверные учётные данные клиента"
+// @Failure      500 {object} Response "Внутренняя ошибка сервера"
+// @Router       /revoke [post]
+// @Security     BasicAuth
+func (h *Handler) Revoke() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zap.S().Infof("Revoke handler start")
+		if err := r.ParseForm(); err != nil || r.PostFormValue("token") == "" {
+			WriteJSONResponse(w, http.StatusBadRequest, Response{
+				Status: "error",
+				Msg:    "token is required",
+			})
+			zap.S().Warnf("Revoke handler error: token is required")
+			return
+		}
+
+		clientID, _ := r.Context().Value(ContextKeyClientID).(string)
+		if err := h.svc.Revoke(r.Context(), clientID, r.PostFormValue("token"), r.PostFormValue("token_type_hint")); err != nil {
+			zap.S().Errorf("failed to revoke token: %v", err)
+			WriteJSONResponse(w, http.StatusInternalServerError, Response{
+				Status: "error",
+				Msg:    "internal server error",
+			})
+			zap.S().Errorf("Revoke handler error: failed to revoke token")
+			return
+		}
+
+		WriteJSONResponse(w, http.StatusOK, Response{
+			Status: "ok",
+		})
+		zap.S().Infof("Revoke handler success")
+	}
+}
+
+// Token
+// @Summary      OAuth2 token endpoint
+// @Description  Выдаёт токены по OAuth2 grant.
+// @Description  grant_type=client_credentials: access токен выдаётся клиенту от его имени, refresh токен не выдаётся.
+// @Description  Если клиент подключился по mTLS, токен привязывается к его сертификату (cnf.x5t#S256, RFC 8705).
+// @Description  grant_type=authorization_code: код из /oauth/authorize обменивается на пару токенов, обязателен code_verifier (PKCE).
+// @Description  Конфиденциальный клиент аутентифицируется через HTTP Basic или параметры client_id и client_secret,
+// @Description  публичный клиент передаёт только client_id.
+// @Tags         oauth
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Param        grant_type    formData string true  "Тип гранта"
+// @Param        scope         formData string false "Запрашиваемые scope через пробел"
+// @Param        client_id     formData string false "client_id, если не передан через HTTP Basic"
+// @Param        client_secret formData string false "Секрет клиента, если не передан через HTTP Basic"
+// @Param        code          formData string false "Код авторизации (authorization_code)"
+// @Param        redirect_uri  formData string false "redirect_uri из запроса авторизации (authorization_code)"
+// @Param        code_verifier formData string false "PKCE verifier (authorization_code)"
+// @Success      200 {object} OAuthTokenResponse
+// @Failure      400 {object} OAuthError "Некорректный запрос, неподдерживаемый grant или scope, либо достигнут лимит сессий"
+// @Failure      401 {object} OAuthError "Неверные учётные данные клиента"
+// @Failure      500 {object} OAuthError "Внутренняя ошибка сервера"
+// @Router       /oauth/token [post]
+func (h *Handler) Token() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zap.S().Infof("Token handler start")
+		if err := r.ParseForm(); err != nil {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "invalid request body")
+			zap.S().Warnf("Token handler error: invalid request body")
+			return
+		}
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok {
+			clientID, clientSecret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+		}
+
+		var issued *service.IssuedToken
+		var err error
+		switch grantType := r.PostFormValue("grant_type"); grantType {
+		case service.GrantTypeClientCredentials:
+			issued, err = h.svc.ClientCredentials(r.Context(), clientID, clientSecret, r.PostFormValue("scope"), mtls.PeerThumbprint(r))
+		case service.GrantTypeAuthorizationCode:
+			ip, _ := r.Context().Value(ContextKeyIP).(string)
+			issued, err = h.svc.ExchangeAuthorizationCode(r.Context(), service.CodeExchangeRequest{
+				ClientID:     clientID,
+				ClientSecret: clientSecret,
+				Code:         r.PostFormValue("code"),
+				RedirectURI:  r.PostFormValue("redirect_uri"),
+				CodeVerifier: r.PostFormValue("code_verifier"),
+				UserAgent:    r.UserAgent(),
+				IP:           ip,
+			})
+		default:
+			writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+			zap.S().Warnf("Token handler error: unsupported grant type %q", grantType)
+			return
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, er.ErrInvalidClient):
+				w.Header().Set("WWW-Authenticate", `Basic realm="auth-service"`)
+				writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "")
+				zap.S().Warnf("Token handler error: invalid client %q", clientID)
+			case errors.Is(err, er.ErrInvalidScope):
+				writeOAuthError(w, http.StatusBadRequest, "invalid_scope", err.Error())
+				zap.S().Warnf("Token handler error: %v", err)
+			case errors.Is(err, er.ErrInvalidGrant):
+				writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "")
+				zap.S().Warnf("Token handler error: %v", err)
+			case errors.Is(err, er.ErrTooManySessions):
+				writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "too many active sessions")
+				zap.S().Warnf("Token handler error: %v", err)
+			case errors.Is(err, er.ErrUserDisabled):
+				writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "user is disabled")
+				zap.S().Warnf("Token handler error: %v", err)
+			default:
+				zap.S().Errorf("failed to issue token: %v", err)
+				writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+				zap.S().Errorf("Token handler error: failed to issue token")
+			}
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		WriteJSON(w, http.StatusOK, OAuthTokenResponse{
+			AccessToken:  issued.AccessToken,
+			TokenType:    "Bearer",
+			ExpiresIn:    issued.ExpiresIn,
+			RefreshToken: issued.RefreshToken,
+			IDToken:      issued.IDToken,
+			Scope:        issued.Scope,
+		})
+		zap.S().Infof("Token handler success")
+	}
+}
+
+func writeOAuthError(w http.ResponseWriter, statusCode int, code, description string) {
+	w.Header().Set("Cache-Control", "no-store")
+	WriteJSON(w, statusCode, OAuthError{Error: code, ErrorDescription: description})
+}
+
+// Authorize
+// @Summary      OAuth2 запрос авторизации
+// @Description  Authorization code grant с обязательным PKCE (S256). При успехе перенаправляет на redirect_uri
+// @Description  с параметрами code и state, при ошибке — с параметром error.
+// @Description  Пользователь определяется по access токену: код выдаётся от имени владельца токена.
+// @Description  Если клиент неизвестен или redirect_uri не зарегистрирован, перенаправления не происходит.
+// @Tags         oauth
+// @Produce      json
+// @Param        response_type         query string true  "Должен быть code"
+// @Param        client_id             query string true  "Идентификатор клиента"
+// @Param        redirect_uri          query string false "Зарегистрированный redirect URI клиента"
+// @Param        scope                 query string false "Запрашиваемые scope через пробел"
+// @Param        state                 query string false "Значение, возвращаемое клиенту без изменений"
+// @Param        nonce                 query string false "OpenID Connect nonce, возвращается в id_token"
+// @Param        code_challenge        query string true  "PKCE challenge"
+// @Param        code_challenge_method query string true  "Должен быть S256"
+// @Success      302
+// @Failure      400 {object} Response "Неизвестный клиент или неверный redirect_uri"
+// @Failure      401 {object} Response "Отсутствует или неверный access токен, либо токен выдан не пользователю"
+// @Failure      500 {object} Response "Внутренняя ошибка сервера"
+// @Router       /oauth/authorize [get]
+// @Security     BearerAuth
+func (h *Handler) Authorize() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zap.S().Infof("Authorize handler start")
+		guid, _ := r.Context().Value(ContextKeyGUID).(string)
+		userID, err := uuid.Parse(guid)
+		if err != nil {
+			WriteJSONResponse(w, http.StatusUnauthorized, Response{
+				Status: "error",
+				Msg:    "access token is not issued to a user",
+			})
+			zap.S().Warnf("Authorize handler error: access token is not issued to a user")
+			return
+		}
+
+		q := r.URL.Query()
+		redirect, err := h.svc.Authorize(r.Context(), service.AuthorizeRequest{
+			ResponseType:        q.Get("response_type"),
+			ClientID:            q.Get("client_id"),
+			RedirectURI:         q.Get("redirect_uri"),
+			Scope:               q.Get("scope"),
+			State:               q.Get("state"),
+			CodeChallenge:       q.Get("code_challenge"),
+			CodeChallengeMethod: q.Get("code_challenge_method"),
+			Nonce:               q.Get("nonce"),
+			UserID:              userID,
+		})
+		if err != nil {
+			if errors.Is(err, er.ErrInvalidClient) || errors.Is(err, er.ErrInvalidRedirect) {
+				WriteJSONResponse(w, http.StatusBadRequest, Response{
+					Status: "error",
+					Msg:    err.Error(),
+				})
+				zap.S().Warnf("Authorize handler error: %v", err)
+				return
+			}
+			zap.S().Errorf("failed to authorize: %v", err)
+			WriteJSONResponse(w, http.StatusInternalServerError, Response{
+				Status: "error",
+				Msg:    "internal server error",
+			})
+			zap.S().Errorf("Authorize handler error: failed to authorize")
+			return
+		}
+
+		http.Redirect(w, r, redirect, http.StatusFound)
+		zap.S().Infof("Authorize handler success")
+	}
+}
+
+// CreateUser
+// @Summary      Создание пользователя
+// @Description  Создаёт пользователя с ролью user. GUID можно передать в теле запроса, иначе он генерируется сервером.
+// @Description  Требует scope users:admin.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        body body CreateUserRequest false "Тело запроса"
+// @Success      201 {object} Response{data=models.User}
+// @Failure      400 {object} Response "Некорректное тело запроса или неверный формат guid"
+// @Failure      401 {object} Response "Отсутствует или неверный access токен"
+// @Failure      403 {object} Response "В access токене нет scope users:admin"
+// @Failure      409 {object} Response "Пользователь с таким guid уже существует"
+// @Failure      500 {object} Response "Внутренняя ошибка сервера"
+// @Router       /admin/users [post]
+// @Security     BearerAuth
+func (h *Handler) CreateUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zap.S().Infof("CreateUser handler start")
+		var req CreateUserRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				WriteJSONResponse(w, http.StatusBadRequest, Response{
+					Status: "error",
+					Msg:    "invalid request body",
+				})
+				zap.S().Warnf("CreateUser handler error: invalid request body: %v", err)
+				return
+			}
+		}
+		id := uuid.Nil
+		if req.GUID != "" {
+			parsed, err := uuid.Parse(req.GUID)
+			if err != nil || parsed == uuid.Nil {
+				WriteJSONResponse(w, http.StatusBadRequest, Response{
+					Status: "error",
+					Msg:    "invalid guid format",
+				})
+				zap.S().Warnf("CreateUser handler error: invalid guid format")
+				return
+			}
+			id = parsed
+		}
+		user, err := h.svc.CreateUser(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, er.ErrAlreadyExists) {
+				WriteJSONResponse(w, http.StatusConflict, Response{
+					Status: "error",
+					Msg:    "user already exists",
+				})
+				zap.S().Warnf("CreateUser handler error: user %s already exists", id)
+				return
+			}
+			zap.S().Errorf("failed to create user: %v", err)
+			WriteJSONResponse(w, http.StatusInternalServerError, Response{
+				Status: "error",
+				Msg:    "internal server error",
+			})
+			zap.S().Errorf("CreateUser handler error: failed to create user")
+			return
+		}
+		WriteJSONResponse(w, http.StatusCreated, Response{
+			Status: "ok",
+			Data:   user,
+		})
+		zap.S().Infof("CreateUser handler success: user %s created", user.ID)
+	}
+}
+
+// DisableUser
+// @Summary      Блокировка пользователя
+// @Description  Блокирует пользователя и завершает все его сессии. Заблокированному пользователю токены не выдаются
+// @Description  и не обновляются. Требует scope users:admin.
+// @Tags         admin
+// @Produce      json
+// @Param        guid path string true "GUID пользователя"
+// @Success      200 {object} Response{data=models.User}
+// @Failure      400 {object} Response "Неверный формат guid"
+// @Failure      401 {object} Response "Отсутствует или неверный access токен"
+// @Failure      403 {object} Response "В access токене нет scope users:admin"
+// @Failure      404 {object} Response "Пользователь не найден"
+// @Failure      500 {object} Response "Внутренняя ошибка сервера"
+// @Router       /admin/users/{guid}/disable [post]
+// @Security     BearerAuth
+func (h *Handler) DisableUser() http.HandlerFunc {
+	return h.updateUser("DisableUser", h.svc.DisableUser)
+}
+
+// EnableUser
+// @Summary      Разблокировка пользователя
+// @Description  Снимает блокировку пользователя. Завершённые при блокировке сессии не восстанавливаются.
+// @Description  Требует scope users:admin.
+// @Tags         admin
+// @Produce      json
+// @Param        guid path string true "GUID пользователя"
+// @Success      200 {object} Response{data=models.User}
+// @Failure      400 {object} Response "Неверный формат guid"
+// @Failure      401 {object} Response "Отсутствует или неверный access токен"
+// @Failure      403 {object} Response "В access токене нет scope users:admin"
+// @Failure      404 {object} Response "Пользователь не найден"
+// @Failure      500 {object} Response "Внутренняя ошибка сервера"
+// @Router       /admin/users/{guid}/enable [post]
+// @Security     BearerAuth
+func (h *Handler) EnableUser() http.HandlerFunc {
+	return h.updateUser("EnableUser", h.svc.EnableUser)
+}
+
+// updateUser общий обработчик блокировки и разблокировки пользователя
+func (h *Handler) updateUser(name string, update func(context.Context, uuid.UUID) (*models.User, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zap.S().Infof("%s handler start", name)
+		id, ok := userIDFromPath(w, r, name)
+		if !ok {
+			return
+		}
+		user, err := update(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, er.ErrNotFound) {
+				WriteJSONResponse(w, http.StatusNotFound, Response{
+					Status: "error",
+					Msg:    "user not found",
+				})
+				zap.S().Warnf("%s handler error: user %s not found", name, id)
+				return
+			}
+			zap.S().Errorf("failed to update user %s: %v", id, err)
+			WriteJSONResponse(w, http.StatusInternalServerError, Response{
+				Status: "error",
+				Msg:    "internal server error",
+			})
+			zap.S().Errorf("%s handler error: failed to update user", name)
+			return
+		}
+		WriteJSONResponse(w, http.StatusOK, Response{
+			Status: "ok",
+			Data:   user,
+		})
+		zap.S().Infof("%s handler success: user %s", name, id)
+	}
+}
+
+// DeleteUser
+// @Summary      Удаление пользователя
+// @Description  Удаляет пользователя вместе с его токенами и ролями; access токены его сессий отзываются.
+// @Description  Требует scope users:admin.
+// @Tags         admin
+// @Produce      json
+// @Param        guid path string true "GUID пользователя"
+// @Success      200 {object} Response
+// @Failure      400 {object} Response "Неверный формат guid"
+// @Failure      401 {object} Response "Отсутствует или неверный access токен"
+// @Failure      403 {object} Response "В access токене нет scope users:admin"
+// @Failure      404 {object} Response "Пользователь не найден"
+// @Failure      500 {object} Response "Внутренняя ошибка сервера"
+// @Router       /admin/users/{guid} [delete]
+// @Security     BearerAuth
+func (h *Handler) DeleteUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zap.S().Infof("DeleteUser handler start")
+		id, ok := userIDFromPath(w, r, "DeleteUser")
+		if !ok {
+			return
+		}
+		if err := h.svc.DeleteUser(r.Context(), id); err != nil {
+			if errors.Is(err, er.ErrNotFound) {
+				WriteJSONResponse(w, http.StatusNotFound, Response{
+					Status: "error",
+					Msg:    "user not found",
+				})
+				zap.S().Warnf("DeleteUser handler error: user %s not found", id)
+				return
+			}
+			zap.S().Errorf("failed to delete user %s: %v", id, err)
+			WriteJSONResponse(w, http.StatusInternalServerError, Response{
+				Status: "error",
+				Msg:    "internal server error",
+			})
+			zap.S().Errorf("DeleteUser handler error: failed to delete user")
+			return
+		}
+		WriteJSONResponse(w, http.StatusOK, Response{
+			Status: "ok",
+			Msg:    "user deleted",
+		})
+		zap.S().Infof("DeleteUser handler success: user %s", id)
+	}
+}
+
+// userIDFromPath разбирает guid из пути; при ошибке сам отвечает 400
+func userIDFromPath(w http.ResponseWriter, r *http.Request, handlerName string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["guid"])
+	if err != nil {
+		WriteJSONResponse(w, http.StatusBadRequest, Response{
+			Status: "error",
+			Msg:    "invalid guid format",
+		})
+		zap.S().Warnf("%s handler error: invalid guid format", handlerName)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// Register
+// @Summary      Регистрация по логину и паролю
+// @Description  Создаёт пользователя с ролью user и паролем. Пароль проверяется политикой паролей:
+// @Description  длина от PASSWORD_MIN_LENGTH до PASSWORD_MAX_LENGTH символов, не совпадает с логином и не входит в BREACHED_PASSWORDS_FILE.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        body body RegisterRequest true "Тело запроса"
+// @Success      201 {object} Response{data=models.User}
+// @Failure      400 {object} Response "Некорректное тело запроса, логин или пароль не соответствует политике"
+// @Failure      409 {object} Response "Логин занят"
+// @Failure      500 {object} Response "Внутренняя ошибка сервера"
+// @Failure      503 {object} Response "Превышен лимит одновременных проверок паролей, повторите позже"
+// @Router       /register [post]
+func (h *Handler) Register() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zap.S().Infof("Register handler start")
+		var req RegisterRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJSONResponse(w, http.StatusBadRequest, Response{
+				Status: "error",
+				Msg:    "invalid request body",
+			})
+			zap.S().Warnf("Register handler error: invalid request body: %v", err)
+			return
+		}
+		user, err := h.svc.Register(r.Context(), req.Login, req.Password)
+		if err != nil {
+			if errors.Is(err, er.ErrInvalidLogin) || errors.Is(err, er.ErrWeakPassword) {
+				WriteJSONResponse(w, http.StatusBadRequest, Response{
+					Status: "error",
+					Msg:    err.Error(),
+				})
+				zap.S().Warnf("Register handler error: %v", err)
+				return
+			}
+			if errors.Is(err, er.ErrAlreadyExists) {
+				WriteJSONResponse(w, http.StatusConflict, Response{
+					Status: "error",
+					Msg:    "login is already taken",
+				})
+				zap.S().Warnf("Register handler error: login is already taken")
+				return
+			}
+			if errors.Is(err, er.ErrServerBusy) {
+				w.Header().Set("Retry-After", "1")
+				WriteJSONResponse(w, http.StatusServiceUnavailable, Response{
+					Status: "error",
+					Msg:    er.ErrServerBusy.Error(),
+				})
+				zap.S().Warnf("Register handler error: too many concurrent password hash operations")
+				return
+			}
+			zap.S().Errorf("failed to register user: %v", err)
+			WriteJSONResponse(w, http.StatusInternalServerError, Response{
+				Status: "error",
+				Msg:    "internal server error",
+			})
+			zap.S().Errorf("Register handler error: failed to register user")
+			return
+		}
+		WriteJSONResponse(w, http.StatusCreated, Response{
+			Status: "ok",
+			Data:   user,
+		})
+		zap.S().Infof("Register handler success: user %s", user.ID)
+	}
+}
+
+// Login
+// @Summary      Вход по логину и паролю
+// @Description  Проверяет пароль и выдаёт пару токенов так же, как /tokens/{guid}. С заголовком DPoP токены привязываются к ключу клиента.
+// @Description  Хеш пароля, вычисленный с устаревшими параметрами Argon2id, пересчитывается при успешном входе.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        body body LoginRequest true "Тело запроса"
+// @Param        DPoP header string false "DPoP proof"
+// @Success      200 {object} Response{data=TokenPair}
+// @Failure      400 {object} Response "Некорректное тело запроса или DPoP proof"
+// @Failure      401 {object} Response "Неверный логин или пароль"
+// @Failure      403 {object} Response "Пользователь заблокирован"
+// @Failure      409 {object} Response "Достигнут MAX_SESSIONS_PER_USER при политике reject"
+// @Failure      500 {object} Response "Внутренняя ошибка сервера"
+// @Failure      503 {object} Response "Превышен лимит одновременных проверок паролей, повторите позже"
+// @Router       /login [post]
+func (h *Handler) Login() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zap.S().Infof("Login handler start")
+		var req LoginRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJSONResponse(w, http.StatusBadRequest, Response{
+				Status: "error",
+				Msg:    "invalid request body",
+			})
+			zap.S().Warnf("Login handler error: invalid request body: %v", err)
+			return
+		}
+		ip, _ := r.Context().Value(ContextKeyIP).(string)
+
+		dpopJKT, err := h.dpopThumbprint(r)
+		if err != nil {
+			WriteJSONResponse(w, http.StatusBadRequest, Response{
+				Status: "error",
+				Msg:    "invalid dpop proof",
+			})
+			zap.S().Warnf("Login handler error: invalid dpop proof")
+			return
+		}
+
+		pair, err := h.svc.Login(r.Context(), req.Login, req.Password, r.UserAgent(), ip, dpopJKT)
+		if err != nil {
+			if errors.Is(err, er.ErrInvalidPassword) || errors.Is(err, er.ErrNotFound) {
+				WriteJSONResponse(w, http.StatusUnauthorized, Response{
+					Status: "error",
+					Msg:    er.ErrInvalidPassword.Error(),
+				})
+				zap.S().Warnf("Login handler error: invalid login or password")
+				return
+			}
+			if errors.Is(err, er.ErrUserDisabled) {
+				WriteJSONResponse(w, http.StatusForbidden, Response{
+					Status: "error",
+					Msg:    "user is disabled",
+				})
+				zap.S().Warnf("Login handler error: user is disabled")
+				return
+			}
+			if errors.Is(err, er.ErrTooManySessions) {
+				WriteJSONResponse(w, http.StatusConflict, Response{
+					Status: "error",
+					Msg:    "too many active sessions, revoke one of them first",
+				})
+				zap.S().Warnf("Login handler error: session limit reached")
+				return
+			}
+			if errors.Is(err, er.ErrServerBusy) {
+				w.Header().Set("Retry-After", "1")
+				WriteJSONResponse(w, http.StatusServiceUnavailable, Response{
+					Status: "error",
+					Msg:    er.ErrServerBusy.Error(),
+				})
+				zap.S().Warnf("Login handler error: too many concurrent password hash operations")
+				return
+			}
+			zap.S().Errorf("failed to log in: %v", err)
+			WriteJSONResponse(w, http.StatusInternalServerError, Response{
+				Status: "error",
+				Msg:    "internal server error",
+			})
+			zap.S().Errorf("Login handler error: failed to log in")
+			return
+		}
+		WriteJSONResponse(w, http.StatusOK, Response{
+			Status: "ok",
+			Data: TokenPair{
+				AccessToken:  pair.AccessToken,
+				RefreshToken: pair.RefreshToken,
+				IDToken:      pair.IDToken,
+				TokenType:    pair.TokenType,
+			},
+		})
+		zap.S().Infof("Login handler success")
+	}
+}
+
+// ChangePassword
+// @Summary      Смена пароля
+// @Description  Меняет пароль текущего пользователя после проверки текущего пароля.
+// @Description  Новый пароль проверяется политикой паролей; все сессии пользователя, кроме текущей, завершаются.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        body body ChangePasswordRequest true "Тело запроса"
+// @Success      200 {object} Response{data=ChangePasswordResponse}
+// @Failure      400 {object} Response "Некорректное тело запроса или новый пароль не соответствует политике"
+// @Failure      401 {object} Response "Отсутствует или неверный access токен, либо неверный текущий пароль"
+// @Failure      404 {object} Response "У пользователя не задан пароль"
+// @Failure      500 {object} Response "Внутренняя ошибка сервера"
+// @Failure      503 {object} Response "Превышен лимит одновременных проверок паролей, повторите позже"
+// @Router       /me/password [post]
+// @Security     BearerAuth
+func (h *Handler) ChangePassword() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zap.S().Infof("ChangePassword handler start")
+		userID, claims, ok := sessionOwner(r)
+		if !ok {
+			WriteJSONResponse(w, http.StatusUnauthorized, Response{
+				Status: "error",
+				Msg:    "access token is not issued to a user",
+			})
+			zap.S().Warnf("ChangePassword handler error: access token is not issued to a user")
+			return
+		}
+		var req ChangePasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJSONResponse(w, http.StatusBadRequest, Response{
+				Status: "error",
+				Msg:    "invalid request body",
+			})
+			zap.S().Warnf("ChangePassword handler error: invalid request body: %v", err)
+			return
+		}
+		revoked, err := h.svc.ChangePassword(r.Context(), userID, claims.SessionID, req.CurrentPassword, req.NewPassword)
+		if err != nil {
+			if errors.Is(err, er.ErrInvalidPassword) {
+				WriteJSONResponse(w, http.StatusUnauthorized, Response{
+					Status: "error",
+					Msg:    "invalid current password",
+				})
+				zap.S().Warnf("ChangePassword handler error: invalid current password of user %s", userID)
+				return
+			}
+			if errors.Is(err, er.ErrWeakPassword) {
+				WriteJSONResponse(w, http.StatusBadRequest, Response{
+					Status: "error",
+					Msg:    err.Error(),
+				})
+				zap.S().Warnf("ChangePassword handler error: %v", err)
+				return
+			}
+			if errors.Is(err, er.ErrNotFound) {
+				WriteJSONResponse(w, http.StatusNotFound, Response{
+					Status: "error",
+					Msg:    "password is not set for user",
+				})
+				zap.S().Warnf("ChangePassword handler error: user %s has no password", userID)
+				return
+			}
+			if errors.Is(err, er.ErrServerBusy) {
+				w.Header().Set("Retry-After", "1")
+				WriteJSONResponse(w, http.StatusServiceUnavailable, Response{
+					Status: "error",
+					Msg:    er.ErrServerBusy.Error(),
+				})
+				zap.S().Warnf("ChangePassword handler error: too many concurrent password hash operations")
+				return
+			}
+			zap.S().Errorf("failed to change password: %v", err)
+			WriteJSONResponse(w, http.StatusInternalServerError, Response{
+				Status: "error",
+				Msg:    "internal server error",
+			})
+			zap.S().Errorf("ChangePassword handler error: failed to change password")
+			return
+		}
+		WriteJSONResponse(w, http.StatusOK, Response{
+			Status: "ok",
+			Msg:    "password changed",
+			Data:   ChangePasswordResponse{RevokedSessions: revoked},
+		})
+		zap.S().Infof("ChangePassword handler success: user %s", userID)
+	}
+}
@@ -2,6 +2,9 @@ package auth
 
 import (
 	"auth-service/internal/httpserver/handler"
+	"auth-service/internal/models"
+	"auth-service/pkg/dpop"
+	"auth-service/pkg/mtls"
 	"context"
 	"net/http"
 	"strings"
@@ -10,9 +13,31 @@ import (
 	"go.uber.org/zap"
 )
 
-type TokenValidator func(token string) (uuid.UUID, error)
+type TokenValidator func(ctx context.Context, token string) (*models.AccessTokenClaims, error)
 
-func Middleware(validate TokenValidator) func(http.Handler) http.Handler {
+// DPoPVerifier проверяет DPoP proof запроса и возвращает отпечаток ключа, которым он подписан
+type DPoPVerifier func(ctx context.Context, proof, method, path, accessToken string) (string, error)
+
+type options struct {
+	verifyDPoP DPoPVerifier
+}
+
+// Option настройка Middleware
+type Option func(*options)
+
+// WithDPoP включает приём токенов, привязанных к DPoP ключу (схема Authorization: DPoP).
+// Без этой опции такие токены отклоняются, так как владение ключом проверить нечем.
+func WithDPoP(verify DPoPVerifier) Option {
+	return func(o *options) {
+		o.verifyDPoP = verify
+	}
+}
+
+func Middleware(validate TokenValidator, opts ...Option) func(http.Handler) http.Handler {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
@@ -25,7 +50,7 @@ func Middleware(validate TokenValidator) func(http.Handler) http.Handler {
 				return
 			}
 			parts := strings.SplitN(authHeader, " ", 2)
-			if len(parts) != 2 || parts[0] != "Bearer" {
+			if len(parts) != 2 || (parts[0] != "Bearer" && parts[0] != "DPoP") {
 				w.Header().Set("Content-Type", "application/json")
 				handler.WriteJSONResponse(w, http.StatusUnauthorized, handler.Response{
 					Status: "error",
@@ -33,9 +58,9 @@ func Middleware(validate TokenValidator) func(http.Handler) http.Handler {
 				})
 				return
 			}
-			token := parts[1]
-			guid, err := validate(token)
-			if err != nil || guid == uuid.Nil {
+			scheme, token := parts[0], parts[1]
+			claims, err := validate(r.Context(), token)
+			if err != nil {
 				zap.S().Infof("auth middleware: invalid access token: %v", err)
 				w.Header().Set("Content-Type", "application/json")
 				handler.WriteJSONResponse(w, http.StatusUnauthorized, handler.Response{
@@ -44,9 +69,115 @@ func Middleware(validate TokenValidator) func(http.Handler) http.Handler {
 				})
 				return
 			}
-			ctx := context.WithValue(r.Context(), handler.ContextKeyGUID, guid.String())
-			ctx = context.WithValue(ctx, handler.ContextKeyAccessToken, token)
+			if !certificateMatches(r, claims) {
+				zap.S().Infof("auth middleware: client certificate does not match access token")
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				handler.WriteJSONResponse(w, http.StatusUnauthorized, handler.Response{
+					Status: "error",
+					Msg:    "client certificate does not match access token",
+				})
+				return
+			}
+			if msg := o.checkDPoP(r, scheme, token, claims); msg != "" {
+				zap.S().Infof("auth middleware: %s", msg)
+				w.Header().Set("WWW-Authenticate", `DPoP error="invalid_dpop_proof"`)
+				handler.WriteJSONResponse(w, http.StatusUnauthorized, handler.Response{
+					Status: "error",
+					Msg:    msg,
+				})
+				return
+			}
+			ctx := context.WithValue(r.Context(), handler.ContextKeyAccessToken, token)
+			ctx = context.WithValue(ctx, handler.ContextKeyClaims, claims)
+			if claims.UserID != uuid.Nil {
+				ctx = context.WithValue(ctx, handler.ContextKeyGUID, claims.UserID.String())
+			}
+			if claims.ClientID != "" {
+				ctx = context.WithValue(ctx, handler.ContextKeyClientID, claims.ClientID)
+			}
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// certificateMatches проверяет, что токен с cnf.x5t#S256 предъявлен по mTLS с тем же сертификатом (RFC 8705)
+func certificateMatches(r *http.Request, claims *models.AccessTokenClaims) bool {
+	if claims.Confirmation == nil || claims.Confirmation.X5TS256 == "" {
+		return true
+	}
+	return mtls.PeerThumbprint(r) == claims.Confirmation.X5TS256
+}
+
+// checkDPoP проверяет, что токен с cnf.jkt предъявлен по схеме DPoP вместе с proof того же ключа,
+// а bearer токен — по схеме Bearer. Возвращает описание ошибки или пустую строку.
+func (o *options) checkDPoP(r *http.Request, scheme, token string, claims *models.AccessTokenClaims) string {
+	var jkt string
+	if claims.Confirmation != nil {
+		jkt = claims.Confirmation.JKT
+	}
+	if jkt == "" {
+		if scheme == "DPoP" {
+			return "access token is not dpop-bound"
+		}
+		return ""
+	}
+	if scheme != "DPoP" || o.verifyDPoP == nil {
+		return "dpop-bound access token requires DPoP authorization"
+	}
+	proof := r.Header.Get(dpop.HeaderName)
+	if proof == "" {
+		return "missing dpop proof"
+	}
+	proofJKT, err := o.verifyDPoP(r.Context(), proof, r.Method, r.URL.Path, token)
+	if err != nil {
+		return "invalid dpop proof"
+	}
+	if proofJKT != jkt {
+		return "dpop proof key does not match access token"
+	}
+	return ""
+}
+
+// RequireScopes пропускает запрос, только если access токен содержит все перечисленные scope.
+// Подключается к отдельным маршрутам после Middleware, иначе claims в контексте отсутствуют.
+func RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(handler.ContextKeyClaims).(*models.AccessTokenClaims)
+			if !ok {
+				zap.S().Errorf("scope middleware: claims not found in context")
+				handler.WriteJSONResponse(w, http.StatusUnauthorized, handler.Response{
+					Status: "error",
+					Msg:    "missing access token",
+				})
+				return
+			}
+			granted := claims.Scopes()
+			var missing []string
+			for _, scope := range scopes {
+				if !containsScope(granted, scope) {
+					missing = append(missing, scope)
+				}
+			}
+			if len(missing) > 0 {
+				zap.S().Infof("scope middleware: token %s lacks scope %v", claims.ID, missing)
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+strings.Join(scopes, " ")+`"`)
+				handler.WriteJSONResponse(w, http.StatusForbidden, handler.Response{
+					Status: "error",
+					Msg:    "insufficient scope: " + strings.Join(missing, " ") + " required",
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
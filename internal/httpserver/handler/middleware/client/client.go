@@ -0,0 +1,43 @@
+package client
+
+import (
+	"auth-service/internal/httpserver/handler"
+	"context"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+type Authenticator func(ctx context.Context, clientID, secret string) error
+
+// Middleware требует аутентификации вызывающего сервиса по HTTP Basic (client_id:secret)
+// или параметрам формы client_id и client_secret (RFC 6749, 2.3.1)
+func Middleware(authenticate Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientID, secret, ok := r.BasicAuth()
+			if !ok {
+				clientID, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+			}
+			if clientID == "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="auth-service"`)
+				handler.WriteJSONResponse(w, http.StatusUnauthorized, handler.Response{
+					Status: "error",
+					Msg:    "missing client credentials",
+				})
+				return
+			}
+			if err := authenticate(r.Context(), clientID, secret); err != nil {
+				zap.S().Infof("client middleware: client %s authentication failed: %v", clientID, err)
+				w.Header().Set("WWW-Authenticate", `Basic realm="auth-service"`)
+				handler.WriteJSONResponse(w, http.StatusUnauthorized, handler.Response{
+					Status: "error",
+					Msg:    "invalid client credentials",
+				})
+				return
+			}
+			ctx := context.WithValue(r.Context(), handler.ContextKeyClientID, clientID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
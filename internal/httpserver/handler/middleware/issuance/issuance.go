@@ -0,0 +1,101 @@
+package issuance
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"auth-service/internal/httpserver/handler"
+)
+
+// errNoCredentials означает, что запрос не содержит учётных данных, которые проверяет аутентификатор
+var errNoCredentials = errors.New("no credentials")
+
+// Authenticator проверяет, что вызывающий вправе получить токены пользователя userID.
+// Возвращает описание вызывающего для журнала или errNoCredentials, если запрос не для этого аутентификатора.
+type Authenticator func(r *http.Request, userID uuid.UUID) (string, error)
+
+type ClientAuthenticator func(ctx context.Context, clientID, secret string) error
+
+type AssertionVerifier func(ctx context.Context, assertion string, userID uuid.UUID) error
+
+// ClientSecret аутентифицирует доверенного клиента по HTTP Basic (client_id:secret)
+func ClientSecret(authenticate ClientAuthenticator) Authenticator {
+	return func(r *http.Request, _ uuid.UUID) (string, error) {
+		clientID, secret, ok := r.BasicAuth()
+		if !ok {
+			return "", errNoCredentials
+		}
+		if err := authenticate(r.Context(), clientID, secret); err != nil {
+			return "", err
+		}
+		return "client " + clientID, nil
+	}
+}
+
+// SignedAssertion принимает утверждение identity front-end о входе пользователя в заголовке Authorization: Bearer
+func SignedAssertion(verify AssertionVerifier) Authenticator {
+	return func(r *http.Request, userID uuid.UUID) (string, error) {
+		scheme, assertion, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return "", errNoCredentials
+		}
+		if err := verify(r.Context(), strings.TrimSpace(assertion), userID); err != nil {
+			return "", err
+		}
+		return "signed assertion", nil
+	}
+}
+
+// Middleware требует, чтобы вызывающий маршрут выдачи токенов прошёл один из аутентификаторов.
+// Пользователь берётся из параметра пути guid; его формат проверяет сам обработчик.
+// В devMode запросы без учётных данных пропускаются, но предъявленные учётные данные всё равно проверяются.
+func Middleware(devMode bool, authenticators ...Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, err := requestUserID(r)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			for _, authenticate := range authenticators {
+				caller, err := authenticate(r, userID)
+				if errors.Is(err, errNoCredentials) {
+					continue
+				}
+				if err != nil {
+					zap.S().Infof("issuance middleware: authentication for user %s failed: %v", userID, err)
+					unauthorized(w, "invalid issuance credentials")
+					return
+				}
+				zap.S().Infof("issuance middleware: tokens for user %s requested by %s", userID, caller)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if devMode {
+				zap.S().Warnf("issuance middleware: unauthenticated issuance for user %s allowed by dev mode", userID)
+				next.ServeHTTP(w, r)
+				return
+			}
+			unauthorized(w, "missing issuance credentials")
+		})
+	}
+}
+
+func requestUserID(r *http.Request) (uuid.UUID, error) {
+	return uuid.Parse(mux.Vars(r)["guid"])
+}
+
+func unauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="auth-service"`)
+	w.Header().Add("WWW-Authenticate", `Bearer realm="auth-service"`)
+	handler.WriteJSONResponse(w, http.StatusUnauthorized, handler.Response{
+		Status: "error",
+		Msg:    msg,
+	})
+}
@@ -10,6 +10,8 @@ type contextKey string
 const ContextKeyGUID contextKey = "guid"
 const ContextKeyIP contextKey = "ip"
 const ContextKeyAccessToken contextKey = "access_token"
+const ContextKeyClientID contextKey = "client_id"
+const ContextKeyClaims contextKey = "claims"
 
 type Response struct {
 	Status string      `json:"status"`
@@ -20,6 +22,8 @@ type Response struct {
 type TokenPair struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token,omitempty"`
+	TokenType    string `json:"token_type"`
 }
 
 type RefreshTokensRequest struct {
@@ -27,12 +31,66 @@ type RefreshTokensRequest struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+// OAuthTokenResponse ответ token endpoint (RFC 6749, 5.1)
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// OAuthError ошибка token endpoint (RFC 6749, 5.2)
+type OAuthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// RevokeOtherSessionsResponse число завершённых сессий
+type RevokeOtherSessionsResponse struct {
+	Revoked int `json:"revoked"`
+}
+
+// CreateUserRequest тело запроса создания пользователя; без guid идентификатор генерирует сервер
+type CreateUserRequest struct {
+	GUID string `json:"guid,omitempty"`
+}
+
+// RegisterRequest тело запроса регистрации
+type RegisterRequest struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+// LoginRequest тело запроса входа по паролю
+type LoginRequest struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+// ChangePasswordRequest тело запроса смены пароля
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ChangePasswordResponse число сессий, завершённых после смены пароля
+type ChangePasswordResponse struct {
+	RevokedSessions int `json:"revoked_sessions"`
+}
+
 type MeResponse struct {
 	GUID string `json:"guid"`
 }
 
 func WriteJSONResponse(w http.ResponseWriter, statusCode int, resp Response) {
+	WriteJSON(w, statusCode, resp)
+}
+
+// WriteJSON пишет произвольное тело без обёртки Response (для стандартных форматов вроде JWKS)
+func WriteJSON(w http.ResponseWriter, statusCode int, body interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
-	_ = json.NewEncoder(w).Encode(resp)
+	_ = json.NewEncoder(w).Encode(body)
 }
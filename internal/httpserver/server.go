@@ -1,7 +1,12 @@
 package httpserver
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -9,29 +14,59 @@ import (
 
 	_ "auth-service/docs"
 	"auth-service/internal/httpserver/handler"
+	"auth-service/internal/httpserver/handler/middleware/auth"
+	"auth-service/internal/service"
 )
 
 type Config struct {
-	Port        string        `env:"SERVER_PORT" envDefault:"8081"`
-	Timeout     time.Duration `env:"TIMEOUT" envDefault:"10s"`
-	IdleTimeout time.Duration `env:"IDLE_TIMEOUT" envDefault:"60s"`
+	Port            string        `env:"SERVER_PORT" envDefault:"8081"`
+	Timeout         time.Duration `env:"TIMEOUT" envDefault:"10s"`
+	IdleTimeout     time.Duration `env:"IDLE_TIMEOUT" envDefault:"60s"`
+	TLSCertFile     string        `env:"TLS_CERT_FILE"`
+	TLSKeyFile      string        `env:"TLS_KEY_FILE"`
+	TLSClientCAFile string        `env:"TLS_CLIENT_CA_FILE"`
 }
 
-func CreateServer(cfg Config, handler *handler.Handler, authMiddleware, ipMiddleware func(http.Handler) http.Handler) *http.Server {
+// TLSEnabled сообщает, что сервер должен слушать по TLS: заданы сертификат и ключ сервера
+func (c Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+func CreateServer(cfg Config, handler *handler.Handler, authMiddleware, ipMiddleware, clientMiddleware, issuanceMiddleware, revocationMiddleware func(http.Handler) http.Handler) (*http.Server, error) {
 	r := mux.NewRouter()
 
 	r.Use(ipMiddleware)
 
 	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
+	r.HandleFunc("/.well-known/jwks.json", handler.JWKS()).Methods(http.MethodGet)
+	r.HandleFunc("/.well-known/openid-configuration", handler.OpenIDConfiguration()).Methods(http.MethodGet)
 
 	api := r.PathPrefix("/api").Subrouter()
 	api.HandleFunc("/tokens/refresh", handler.RefreshTokens()).Methods(http.MethodPost)
-	api.HandleFunc("/tokens/{guid}", handler.GenerateTokens()).Methods(http.MethodPost)
+	api.Handle("/tokens/{guid}", issuanceMiddleware(handler.GenerateTokens())).Methods(http.MethodPost)
+	api.Handle("/revoke", revocationMiddleware(handler.Revoke())).Methods(http.MethodPost)
+	api.HandleFunc("/register", handler.Register()).Methods(http.MethodPost)
+	api.HandleFunc("/login", handler.Login()).Methods(http.MethodPost)
+	api.HandleFunc("/oauth/token", handler.Token()).Methods(http.MethodPost)
 
 	protected := api.NewRoute().Subrouter()
 	protected.Use(authMiddleware)
 	protected.HandleFunc("/me", handler.GetMe()).Methods(http.MethodGet)
 	protected.HandleFunc("/logout", handler.Logout()).Methods(http.MethodPost)
+	protected.HandleFunc("/oauth/authorize", handler.Authorize()).Methods(http.MethodGet)
+	protected.HandleFunc("/me/password", handler.ChangePassword()).Methods(http.MethodPost)
+	protected.Handle("/me/sessions", auth.RequireScopes("sessions:read")(handler.ListSessions())).Methods(http.MethodGet)
+	protected.Handle("/me/sessions/revoke-others", auth.RequireScopes("sessions:write")(handler.RevokeOtherSessions())).Methods(http.MethodPost)
+	protected.Handle("/me/sessions/{id}", auth.RequireScopes("sessions:write")(handler.RevokeSession())).Methods(http.MethodDelete)
+	protected.Handle("/admin/users", auth.RequireScopes(service.ScopeUsersAdmin)(handler.CreateUser())).Methods(http.MethodPost)
+	protected.Handle("/admin/users/{guid}", auth.RequireScopes(service.ScopeUsersAdmin)(handler.DeleteUser())).Methods(http.MethodDelete)
+	protected.Handle("/admin/users/{guid}/disable", auth.RequireScopes(service.ScopeUsersAdmin)(handler.DisableUser())).Methods(http.MethodPost)
+	protected.Handle("/admin/users/{guid}/enable", auth.RequireScopes(service.ScopeUsersAdmin)(handler.EnableUser())).Methods(http.MethodPost)
+	protected.Handle("/userinfo", auth.RequireScopes("openid")(handler.UserInfo())).Methods(http.MethodGet, http.MethodPost)
+
+	clientProtected := api.NewRoute().Subrouter()
+	clientProtected.Use(clientMiddleware)
+	clientProtected.HandleFunc("/introspect", handler.Introspect()).Methods(http.MethodPost)
 
 	server := &http.Server{
 		Addr:         ":" + cfg.Port,
@@ -41,5 +76,45 @@ func CreateServer(cfg Config, handler *handler.Handler, authMiddleware, ipMiddle
 		IdleTimeout:  cfg.IdleTimeout,
 	}
 
-	return server
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if cfg.TLSClientCAFile != "" {
+		if !cfg.TLSEnabled() {
+			return nil, errors.New("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		tlsConfig, err := clientCertTLSConfig(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		server.TLSConfig = tlsConfig
+	}
+
+	return server, nil
+}
+
+// Serve запускает сервер по TLS или по HTTP в зависимости от конфигурации
+func Serve(server *http.Server, cfg Config) error {
+	if cfg.TLSEnabled() {
+		return server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+	}
+	return server.ListenAndServe()
+}
+
+// clientCertTLSConfig запрашивает у клиента сертификат и проверяет его по CA из caFile.
+// Клиенты без сертификата обслуживаются как обычно; привязанные к сертификату токены они предъявить не смогут.
+func clientCertTLSConfig(caFile string) (*tls.Config, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client ca file %s: %w", caFile, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in client ca file %s", caFile)
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  pool,
+	}, nil
 }
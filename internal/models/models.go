@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -12,24 +13,179 @@ type User struct {
 	ID        uuid.UUID `db:"id" json:"id"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	// DisabledAt момент блокировки; заблокированному пользователю токены не выдаются и не обновляются
+	DisabledAt *time.Time `db:"disabled_at" json:"disabled_at,omitempty"`
+}
+
+// Disabled сообщает, заблокирован ли пользователь
+func (u *User) Disabled() bool {
+	return u.DisabledAt != nil
+}
+
+// Credentials логин и хеш пароля пользователя
+type Credentials struct {
+	UserID uuid.UUID `db:"user_id" json:"user_id"`
+	// Login уникальное имя для входа, хранится в нижнем регистре
+	Login string `db:"login" json:"login"`
+	// PasswordHash хеш Argon2id в формате PHC
+	PasswordHash string    `db:"password_hash" json:"-"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at" json:"updated_at"`
 }
 
 // RefreshToken представляет refresh токен пользователя
 type RefreshToken struct {
 	ID        int       `db:"id" json:"id"`
 	UserID    uuid.UUID `db:"user_id" json:"user_id"`
+	Selector  string    `db:"selector" json:"-"`
 	TokenHash string    `db:"token_hash" json:"-"`
 	UserAgent string    `db:"user_agent" json:"user_agent"`
 	IP        string    `db:"ip" json:"ip"`
 	IssuedAt  time.Time `db:"issued_at" json:"issued_at"`
 	ExpiresAt time.Time `db:"expires_at" json:"expires_at"`
 	IsValid   bool      `db:"is_valid" json:"is_valid"`
+	// FamilyID общий для всей цепочки токенов, полученных ротацией из одного выпуска
+	FamilyID uuid.UUID `db:"family_id" json:"family_id"`
+	// ParentID токен, при ротации которого выпущен этот
+	ParentID *int `db:"parent_id" json:"parent_id,omitempty"`
+	// RotatedAt момент ротации; повторное предъявление ротированного токена считается кражей
+	RotatedAt *time.Time `db:"rotated_at" json:"rotated_at,omitempty"`
+	// AccessJTI jti access токена, выпущенного в паре с этим refresh токеном
+	AccessJTI uuid.UUID `db:"access_jti" json:"-"`
+	// ClientID OAuth2 клиент, получивший сессию через authorization code (пусто для прямой выдачи)
+	ClientID string `db:"client_id" json:"client_id,omitempty"`
+	// Scope запрошенный OAuth2 клиентом scope; для прямой выдачи пуст и токены получают все scope ролей
+	Scope string `db:"scope" json:"scope,omitempty"`
+	// DPoPJKT отпечаток DPoP ключа, к которому привязана сессия (пусто для bearer сессий)
+	DPoPJKT string `db:"dpop_jkt" json:"-"`
+	// Device разобранный UserAgent; пуст у токенов, выпущенных до появления разбора
+	Device Device `json:"device"`
+	// LastUsedAt момент последнего использования сессии: выдачи или обновления этого токена
+	LastUsedAt time.Time `db:"last_used_at" json:"last_used_at"`
+	// RefreshCount сколько раз обновлялась сессия к моменту выпуска токена; переносится при ротации
+	RefreshCount int `db:"refresh_count" json:"refresh_count"`
+}
+
+// ActiveSession активная сессия: действующий refresh токен семейства и начало всей цепочки ротаций
+type ActiveSession struct {
+	Token *RefreshToken
+	// StartedAt момент выдачи первого токена семейства
+	StartedAt time.Time
+}
+
+// SessionIP адрес, с которого использовалась сессия, по данным истории session_ip_history
+type SessionIP struct {
+	FamilyID    uuid.UUID `db:"family_id" json:"-"`
+	IP          string    `db:"ip" json:"ip"`
+	FirstSeenAt time.Time `db:"first_seen_at" json:"first_seen_at"`
+	LastSeenAt  time.Time `db:"last_seen_at" json:"last_seen_at"`
+	// Uses число выдач и обновлений токенов сессии с этого адреса
+	Uses int `db:"uses" json:"uses"`
+}
+
+// Device сведения об устройстве, разобранные из User-Agent
+type Device struct {
+	Browser string `db:"ua_browser" json:"browser"`
+	// BrowserMajor мажорная версия браузера, 0 если не определена
+	BrowserMajor int    `db:"ua_browser_major" json:"browser_major"`
+	OS           string `db:"ua_os" json:"os"`
+	// Type desktop, mobile или bot; пусто, если User-Agent не разобран
+	Type string `db:"ua_device" json:"type"`
+}
+
+// SessionLimit ограничение числа одновременных активных сессий пользователя
+type SessionLimit struct {
+	Max int
+	// EvictOldest при достижении Max завершать самую старую сессию вместо отказа во входе
+	EvictOldest bool
+}
+
+// Client OAuth2 клиент. Конфиденциальный клиент (сервис) имеет секрет,
+// публичный (SPA, мобильное приложение) — только зарегистрированные redirect URI.
+type Client struct {
+	ClientID     string    `db:"client_id" json:"client_id"`
+	SecretHash   string    `db:"secret_hash" json:"-"`
+	Scopes       []string  `db:"scopes" json:"scopes"`
+	RedirectURIs []string  `db:"redirect_uris" json:"redirect_uris"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// IsPublic сообщает, что клиент не может хранить секрет
+func (c *Client) IsPublic() bool {
+	return c.SecretHash == ""
+}
+
+// Role роль пользователя; scope всех ролей пользователя попадают в его access токен
+type Role struct {
+	Name      string    `db:"name" json:"name"`
+	Scopes    []string  `db:"scopes" json:"scopes"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// AuthorizationCode одноразовый код авторизации (RFC 6749, 4.1) с PKCE challenge
+type AuthorizationCode struct {
+	CodeHash      string     `db:"code_hash" json:"-"`
+	ClientID      string     `db:"client_id" json:"client_id"`
+	UserID        uuid.UUID  `db:"user_id" json:"user_id"`
+	RedirectURI   string     `db:"redirect_uri" json:"redirect_uri"`
+	CodeChallenge string     `db:"code_challenge" json:"-"`
+	Scope         string     `db:"scope" json:"scope"`
+	Nonce         string     `db:"nonce" json:"-"`
+	ExpiresAt     time.Time  `db:"expires_at" json:"expires_at"`
+	UsedAt        *time.Time `db:"used_at" json:"used_at,omitempty"`
 }
 
 // AccessTokenClaims используется для генерации и проверки JWT access токена
 // Не хранится в базе, только для работы с JWT
+// jti (RegisteredClaims.ID) уникален для каждого токена, sid совпадает с FamilyID refresh токена.
+// У токенов клиента (client credentials) UserID пустой, а client_id совпадает с sub.
 type AccessTokenClaims struct {
 	UserID    uuid.UUID `json:"user_id"`
-	ExpiresAt int64     `json:"exp"`
+	SessionID uuid.UUID `json:"sid"`
+	ClientID  string    `json:"client_id,omitempty"`
+	Scope     string    `json:"scope,omitempty"`
+	Roles     []string  `json:"roles,omitempty"`
+	// Confirmation ключ, к которому привязан токен (RFC 7800); nil для bearer токенов
+	Confirmation *Confirmation `json:"cnf,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Confirmation claim cnf: отпечаток ключа, владение которым клиент доказывает при предъявлении токена
+type Confirmation struct {
+	// JKT SHA-256 отпечаток DPoP ключа по RFC 7638 (RFC 9449)
+	JKT string `json:"jkt,omitempty"`
+	// X5TS256 SHA-256 отпечаток клиентского TLS сертификата (RFC 8705)
+	X5TS256 string `json:"x5t#S256,omitempty"`
+}
+
+// IDTokenClaims claims OpenID Connect id_token
+type IDTokenClaims struct {
+	Nonce     string    `json:"nonce,omitempty"`
+	SessionID uuid.UUID `json:"sid"`
+	AuthTime  int64     `json:"auth_time,omitempty"`
 	jwt.RegisteredClaims
 }
+
+// JTI возвращает идентификатор токена или uuid.Nil, если он отсутствует
+func (c *AccessTokenClaims) JTI() uuid.UUID {
+	id, err := uuid.Parse(c.ID)
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
+
+// Scopes возвращает scope токена списком
+func (c *AccessTokenClaims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// Expiry возвращает момент истечения токена или нулевое время, если exp отсутствует
+func (c *AccessTokenClaims) Expiry() time.Time {
+	if c.ExpiresAt == nil {
+		return time.Time{}
+	}
+	return c.ExpiresAt.Time
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -13,6 +14,15 @@ import (
 	"auth-service/pkg/er"
 )
 
+// userColumns список колонок в порядке, ожидаемом scanUser
+const userColumns = `id, created_at, updated_at, disabled_at`
+
+// credentialsColumns список колонок в порядке, ожидаемом scanCredentials
+const credentialsColumns = `user_id, login, password_hash, created_at, updated_at`
+
+// refreshTokenColumns список колонок в порядке, ожидаемом scanRefreshToken
+const refreshTokenColumns = `id, user_id, selector, token_hash, user_agent, ip, issued_at, expires_at, is_valid, family_id, parent_id, rotated_at, access_jti, client_id, scope, dpop_jkt, ua_browser, ua_browser_major, ua_os, ua_device, last_used_at, refresh_count`
+
 type Postgres struct {
 	pool *pgxpool.Pool
 }
@@ -27,40 +37,249 @@ func NewPostgres(connString string) (*Postgres, error) {
 
 // GetUserByID получает пользователя по его UUID
 func (p *Postgres) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
-	query := `SELECT id, created_at, updated_at FROM users WHERE id = $1`
-	var user models.User
-	err := p.pool.QueryRow(ctx, query, id).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	user, err := scanUser(p.pool.QueryRow(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, er.ErrNotFound
 		}
 		return nil, fmt.Errorf("failed to get user by id %s: %w", id, err)
 	}
-	return &user, nil
+	return user, nil
+}
+
+// CreateUser создаёт пользователя и назначает ему роль по умолчанию.
+// Возвращает er.ErrAlreadyExists, если пользователь с таким UUID уже есть.
+func (p *Postgres) CreateUser(ctx context.Context, id uuid.UUID, defaultRole string) (*models.User, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := insertUser(ctx, tx, id, defaultRole)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit user %s: %w", id, err)
+	}
+	return user, nil
+}
+
+// CreateUserWithCredentials создаёт пользователя с ролью по умолчанию и учётными данными в одной транзакции.
+// Возвращает er.ErrAlreadyExists, если занят UUID или логин.
+func (p *Postgres) CreateUserWithCredentials(ctx context.Context, id uuid.UUID, defaultRole string, credentials *models.Credentials) (*models.User, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := insertUser(ctx, tx, id, defaultRole)
+	if err != nil {
+		return nil, err
+	}
+	query := `INSERT INTO credentials (user_id, login, password_hash) VALUES ($1, $2, $3)
+		ON CONFLICT (login) DO NOTHING RETURNING ` + credentialsColumns
+	created, err := scanCredentials(tx.QueryRow(ctx, query, id, credentials.Login, credentials.PasswordHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, er.ErrAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to create credentials of user %s: %w", id, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit user %s: %w", id, err)
+	}
+	*credentials = *created
+	return user, nil
+}
+
+// insertUser добавляет пользователя и назначает ему роль в рамках транзакции
+func insertUser(ctx context.Context, tx pgx.Tx, id uuid.UUID, role string) (*models.User, error) {
+	query := `INSERT INTO users (id) VALUES ($1) ON CONFLICT (id) DO NOTHING RETURNING ` + userColumns
+	user, err := scanUser(tx.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, er.ErrAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to create user %s: %w", id, err)
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO user_roles (user_id, role) VALUES ($1, $2)`, id, role); err != nil {
+		return nil, fmt.Errorf("failed to assign role %s to user %s: %w", role, id, err)
+	}
+	return user, nil
+}
+
+// GetCredentialsByLogin получает учётные данные по логину
+func (p *Postgres) GetCredentialsByLogin(ctx context.Context, login string) (*models.Credentials, error) {
+	query := `SELECT ` + credentialsColumns + ` FROM credentials WHERE login = $1`
+	credentials, err := scanCredentials(p.pool.QueryRow(ctx, query, login))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, er.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get credentials by login: %w", err)
+	}
+	return credentials, nil
+}
+
+// GetCredentialsByUserID получает учётные данные пользователя
+func (p *Postgres) GetCredentialsByUserID(ctx context.Context, userID uuid.UUID) (*models.Credentials, error) {
+	query := `SELECT ` + credentialsColumns + ` FROM credentials WHERE user_id = $1`
+	credentials, err := scanCredentials(p.pool.QueryRow(ctx, query, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, er.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get credentials of user %s: %w", userID, err)
+	}
+	return credentials, nil
+}
+
+// UpdatePasswordHash заменяет хеш пароля, только если он всё ещё равен oldHash.
+// Возвращает er.ErrNotFound, если хеш успели изменить или учётных данных нет.
+func (p *Postgres) UpdatePasswordHash(ctx context.Context, userID uuid.UUID, oldHash, newHash string) error {
+	query := `UPDATE credentials SET password_hash = $3, updated_at = NOW() WHERE user_id = $1 AND password_hash = $2`
+	cmd, err := p.pool.Exec(ctx, query, userID, oldHash, newHash)
+	if err != nil {
+		return fmt.Errorf("failed to update password hash of user %s: %w", userID, err)
+	}
+	if cmd.RowsAffected() == 0 {
+		return er.ErrNotFound
+	}
+	return nil
+}
+
+// SetUserDisabled блокирует или разблокирует пользователя. Повторная блокировка сохраняет исходный момент блокировки.
+func (p *Postgres) SetUserDisabled(ctx context.Context, id uuid.UUID, disabled bool) (*models.User, error) {
+	query := `UPDATE users SET
+			disabled_at = CASE WHEN $2 THEN COALESCE(disabled_at, NOW()) END,
+			updated_at = NOW()
+		WHERE id = $1 RETURNING ` + userColumns
+	user, err := scanUser(p.pool.QueryRow(ctx, query, id, disabled))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, er.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to update user %s: %w", id, err)
+	}
+	return user, nil
+}
+
+// DeleteUser удаляет пользователя; его токены, роли и история сессий удаляются каскадно
+func (p *Postgres) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	cmd, err := p.pool.Exec(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user %s: %w", id, err)
+	}
+	if cmd.RowsAffected() == 0 {
+		return er.ErrNotFound
+	}
+	return nil
 }
 
 // CreateRefreshToken сохраняет refresh токен
 func (p *Postgres) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
-	query := `INSERT INTO refresh_tokens (user_id, token_hash, user_agent, ip, issued_at, expires_at, is_valid) VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	_, err := p.pool.Exec(ctx, query, token.UserID, token.TokenHash, token.UserAgent, token.IP, token.IssuedAt, token.ExpiresAt, token.IsValid)
+	return insertRefreshToken(ctx, p.pool, token)
+}
+
+// CreateSession сохраняет первый refresh токен новой сессии, соблюдая лимит активных сессий пользователя.
+// Строка пользователя блокируется на время транзакции, поэтому параллельные входы не превышают лимит.
+// При достижении лимита завершает самые старые сессии и возвращает их валидные токены,
+// либо, если вытеснение не разрешено, возвращает er.ErrTooManySessions.
+func (p *Postgres) CreateSession(ctx context.Context, token *models.RefreshToken, limit models.SessionLimit) ([]*models.RefreshToken, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, token.UserID); err != nil {
+		return nil, fmt.Errorf("failed to lock user %s: %w", token.UserID, err)
+	}
+
+	// активные сессии, начиная с самой старой по времени входа
+	query := `SELECT family_id FROM refresh_tokens WHERE user_id = $1
+		GROUP BY family_id
+		HAVING bool_or(is_valid AND expires_at > NOW())
+		ORDER BY MIN(issued_at)`
+	rows, err := tx.Query(ctx, query, token.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active sessions for user %s: %w", token.UserID, err)
+	}
+	families, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan active sessions for user %s: %w", token.UserID, err)
+	}
+
+	var evicted []*models.RefreshToken
+	if excess := len(families) - limit.Max + 1; excess > 0 {
+		if !limit.EvictOldest {
+			return nil, er.ErrTooManySessions
+		}
+		query := `UPDATE refresh_tokens SET is_valid = false
+			WHERE user_id = $1 AND family_id = ANY($2) AND is_valid = true AND expires_at > NOW()
+			RETURNING ` + refreshTokenColumns
+		rows, err := tx.Query(ctx, query, token.UserID, families[:excess])
+		if err != nil {
+			return nil, fmt.Errorf("failed to evict sessions of user %s: %w", token.UserID, err)
+		}
+		evicted, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.RefreshToken, error) {
+			return scanRefreshToken(row)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan evicted refresh tokens of user %s: %w", token.UserID, err)
+		}
+	}
+
+	if err := insertRefreshToken(ctx, tx, token); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit session of user %s: %w", token.UserID, err)
+	}
+	return evicted, nil
+}
+
+// rowQuerier общая часть pgxpool.Pool и pgx.Tx
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// insertRefreshToken сохраняет refresh токен и в том же запросе дописывает его IP в историю сессии
+func insertRefreshToken(ctx context.Context, q rowQuerier, token *models.RefreshToken) error {
+	query := `WITH token AS (
+			INSERT INTO refresh_tokens (user_id, selector, token_hash, user_agent, ip, issued_at, expires_at, is_valid, family_id, parent_id, access_jti, client_id, scope, dpop_jkt,
+				ua_browser, ua_browser_major, ua_os, ua_device, last_used_at, refresh_count)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), $13, NULLIF($14, ''), $15, $16, $17, $18, $19, $20)
+			RETURNING id, user_id, family_id, ip, user_agent, last_used_at
+		), history AS (
+			INSERT INTO session_ip_history (family_id, user_id, ip, user_agent, seen_at)
+			SELECT family_id, user_id, ip, user_agent, last_used_at FROM token
+		)
+		SELECT id FROM token`
+	err := q.QueryRow(ctx, query, token.UserID, token.Selector, token.TokenHash, token.UserAgent, token.IP, token.IssuedAt, token.ExpiresAt, token.IsValid,
+		token.FamilyID, token.ParentID, token.AccessJTI, token.ClientID, token.Scope, token.DPoPJKT,
+		token.Device.Browser, token.Device.BrowserMajor, token.Device.OS, token.Device.Type, token.LastUsedAt, token.RefreshCount).Scan(&token.ID)
 	if err != nil {
 		return fmt.Errorf("failed to create refresh token for user %s: %w", token.UserID, err)
 	}
 	return nil
 }
 
-// GetRefreshToken получает refresh токен по хешу
-func (p *Postgres) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
-	query := `SELECT id, user_id, token_hash, user_agent, ip, issued_at, expires_at, is_valid FROM refresh_tokens WHERE token_hash = $1`
-	var token models.RefreshToken
-	err := p.pool.QueryRow(ctx, query, tokenHash).Scan(&token.ID, &token.UserID, &token.TokenHash, &token.UserAgent, &token.IP, &token.IssuedAt, &token.ExpiresAt, &token.IsValid)
+// GetRefreshTokenBySelector получает refresh токен по уникальному селектору
+func (p *Postgres) GetRefreshTokenBySelector(ctx context.Context, selector string) (*models.RefreshToken, error) {
+	query := `SELECT ` + refreshTokenColumns + ` FROM refresh_tokens WHERE selector = $1`
+	token, err := scanRefreshToken(p.pool.QueryRow(ctx, query, selector))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, er.ErrNotFound
 		}
-		return nil, fmt.Errorf("failed to get refresh token by hash: %w", err)
+		return nil, fmt.Errorf("failed to get refresh token by selector: %w", err)
 	}
-	return &token, nil
+	return token, nil
 }
 
 // InvalidateRefreshToken делает refresh токен невалидным
@@ -76,6 +295,95 @@ func (p *Postgres) InvalidateRefreshToken(ctx context.Context, tokenHash string)
 	return nil
 }
 
+// RotateAndInsertRefreshToken помечает валидный refresh токен parentID как ротированный и сохраняет его преемника
+// в одной транзакции под той же блокировкой строки пользователя, что и CreateSession: цепочка не остаётся
+// без валидного токена ни для параллельного входа, ни при ошибке сохранения.
+// Возвращает er.ErrNotFound, если токен уже не валиден (например, его ротировал параллельный запрос).
+func (p *Postgres) RotateAndInsertRefreshToken(ctx context.Context, parentID int, token *models.RefreshToken) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, token.UserID); err != nil {
+		return fmt.Errorf("failed to lock user %s: %w", token.UserID, err)
+	}
+	query := `UPDATE refresh_tokens SET is_valid = false, rotated_at = NOW() WHERE id = $1 AND is_valid = true`
+	cmd, err := tx.Exec(ctx, query, parentID)
+	if err != nil {
+		return fmt.Errorf("failed to rotate refresh token %d: %w", parentID, err)
+	}
+	if cmd.RowsAffected() == 0 {
+		return er.ErrNotFound
+	}
+	if err := insertRefreshToken(ctx, tx, token); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit rotation of refresh token %d: %w", parentID, err)
+	}
+	return nil
+}
+
+// InvalidateTokenFamily делает невалидными все токены цепочки пользователя
+func (p *Postgres) InvalidateTokenFamily(ctx context.Context, userID, familyID uuid.UUID) error {
+	query := `UPDATE refresh_tokens SET is_valid = false WHERE user_id = $1 AND family_id = $2`
+	_, err := p.pool.Exec(ctx, query, userID, familyID)
+	if err != nil {
+		return fmt.Errorf("failed to invalidate token family %s: %w", familyID, err)
+	}
+	return nil
+}
+
+// RevokeTokenFamily делает невалидной цепочку familyID пользователя и возвращает её токены,
+// отобранные так же, как в GetLiveUserRefreshTokens
+func (p *Postgres) RevokeTokenFamily(ctx context.Context, userID, familyID uuid.UUID, issuedAfter time.Time) ([]*models.RefreshToken, error) {
+	return p.revokeLiveTokens(ctx, userID, `family_id = $2`, familyID, issuedAfter)
+}
+
+// RevokeUserTokensExcept делает невалидными цепочки пользователя, кроме keepFamilyID, и возвращает их токены,
+// отобранные так же, как в GetLiveUserRefreshTokens
+func (p *Postgres) RevokeUserTokensExcept(ctx context.Context, userID, keepFamilyID uuid.UUID, issuedAfter time.Time) ([]*models.RefreshToken, error) {
+	return p.revokeLiveTokens(ctx, userID, `family_id <> $2`, keepFamilyID, issuedAfter)
+}
+
+// revokeLiveTokens читает и инвалидирует токены цепочек пользователя, подходящих под familyCondition, в одной транзакции
+// под той же блокировкой строки пользователя, что и RotateAndInsertRefreshToken: параллельная ротация либо успевает
+// до чтения и её токен попадает в результат, либо после и не находит валидного родителя.
+func (p *Postgres) revokeLiveTokens(ctx context.Context, userID uuid.UUID, familyCondition string, familyID uuid.UUID, issuedAfter time.Time) ([]*models.RefreshToken, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return nil, fmt.Errorf("failed to lock user %s: %w", userID, err)
+	}
+	query := `SELECT ` + refreshTokenColumns + ` FROM refresh_tokens
+		WHERE user_id = $1 AND ` + familyCondition + ` AND ((is_valid = true AND expires_at > NOW()) OR issued_at > $3)`
+	rows, err := tx.Query(ctx, query, userID, familyID, issuedAfter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get live refresh tokens for user %s: %w", userID, err)
+	}
+	tokens, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.RefreshToken, error) {
+		return scanRefreshToken(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan live refresh tokens for user %s: %w", userID, err)
+	}
+
+	query = `UPDATE refresh_tokens SET is_valid = false WHERE user_id = $1 AND ` + familyCondition + ` AND is_valid = true`
+	if _, err := tx.Exec(ctx, query, userID, familyID); err != nil {
+		return nil, fmt.Errorf("failed to invalidate refresh tokens for user %s: %w", userID, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit revocation for user %s: %w", userID, err)
+	}
+	return tokens, nil
+}
+
 // InvalidateAllUserTokens делает все refresh токены пользователя невалидными
 func (p *Postgres) InvalidateAllUserTokens(ctx context.Context, userID uuid.UUID) error {
 	query := `UPDATE refresh_tokens SET is_valid = false WHERE user_id = $1`
@@ -88,7 +396,7 @@ func (p *Postgres) InvalidateAllUserTokens(ctx context.Context, userID uuid.UUID
 
 // GetUserRefreshTokens получает все refresh токены пользователя
 func (p *Postgres) GetUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]*models.RefreshToken, error) {
-	query := `SELECT id, user_id, token_hash, user_agent, ip, issued_at, expires_at, is_valid FROM refresh_tokens WHERE user_id = $1`
+	query := `SELECT ` + refreshTokenColumns + ` FROM refresh_tokens WHERE user_id = $1`
 	rows, err := p.pool.Query(ctx, query, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get refresh tokens for user %s: %w", userID, err)
@@ -97,11 +405,11 @@ func (p *Postgres) GetUserRefreshTokens(ctx context.Context, userID uuid.UUID) (
 
 	var tokens []*models.RefreshToken
 	for rows.Next() {
-		var token models.RefreshToken
-		if err := rows.Scan(&token.ID, &token.UserID, &token.TokenHash, &token.UserAgent, &token.IP, &token.IssuedAt, &token.ExpiresAt, &token.IsValid); err != nil {
+		token, err := scanRefreshToken(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan refresh token for user %s: %w", userID, err)
 		}
-		tokens = append(tokens, &token)
+		tokens = append(tokens, token)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("failed to scan refresh tokens for user %s: %w", userID, err)
@@ -109,25 +417,232 @@ func (p *Postgres) GetUserRefreshTokens(ctx context.Context, userID uuid.UUID) (
 	return tokens, nil
 }
 
-// GetValidUserRefreshTokens получает только валидные refresh токены пользователя
-func (p *Postgres) GetValidUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]*models.RefreshToken, error) {
-	query := `SELECT id, user_id, token_hash, user_agent, ip, issued_at, expires_at, is_valid FROM refresh_tokens WHERE user_id = $1 AND is_valid = true AND expires_at > NOW()`
-	rows, err := p.pool.Query(ctx, query, userID)
+// GetLiveUserRefreshTokens получает валидные refresh токены пользователя и все его токены, выпущенные после issuedAfter,
+// в том числе ротированные и инвалидированные: выпущенные с ними access токены ещё могут быть предъявлены
+func (p *Postgres) GetLiveUserRefreshTokens(ctx context.Context, userID uuid.UUID, issuedAfter time.Time) ([]*models.RefreshToken, error) {
+	query := `SELECT ` + refreshTokenColumns + ` FROM refresh_tokens
+		WHERE user_id = $1 AND ((is_valid = true AND expires_at > NOW()) OR issued_at > $2)`
+	rows, err := p.pool.Query(ctx, query, userID, issuedAfter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get valid refresh tokens for user %s: %w", userID, err)
+		return nil, fmt.Errorf("failed to get live refresh tokens for user %s: %w", userID, err)
 	}
 	defer rows.Close()
 
 	var tokens []*models.RefreshToken
 	for rows.Next() {
-		var token models.RefreshToken
-		if err := rows.Scan(&token.ID, &token.UserID, &token.TokenHash, &token.UserAgent, &token.IP, &token.IssuedAt, &token.ExpiresAt, &token.IsValid); err != nil {
-			return nil, fmt.Errorf("failed to scan valid refresh token for user %s: %w", userID, err)
+		token, err := scanRefreshToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan live refresh token for user %s: %w", userID, err)
 		}
-		tokens = append(tokens, &token)
+		tokens = append(tokens, token)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("failed to scan valid refresh tokens for user %s: %w", userID, err)
+		return nil, fmt.Errorf("failed to scan live refresh tokens for user %s: %w", userID, err)
 	}
 	return tokens, nil
 }
+
+// GetUserActiveSessions получает активные сессии пользователя: по одному действующему refresh токену на семейство
+// и момент выдачи первого токена семейства. Ротированные токены не выгружаются, они только агрегируются в SQL.
+func (p *Postgres) GetUserActiveSessions(ctx context.Context, userID uuid.UUID) ([]*models.ActiveSession, error) {
+	query := `WITH active AS (
+			SELECT DISTINCT ON (family_id) ` + refreshTokenColumns + ` FROM refresh_tokens
+			WHERE user_id = $1 AND is_valid = true AND expires_at > NOW()
+			ORDER BY family_id, issued_at DESC
+		), families AS (
+			SELECT family_id, MIN(issued_at) AS started_at FROM refresh_tokens
+			WHERE family_id IN (SELECT family_id FROM active)
+			GROUP BY family_id
+		)
+		SELECT ` + refreshTokenColumns + `, started_at FROM active JOIN families USING (family_id)
+		ORDER BY started_at DESC`
+	rows, err := p.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active sessions for user %s: %w", userID, err)
+	}
+	defer rows.Close()
+
+	var sessions []*models.ActiveSession
+	for rows.Next() {
+		var session models.ActiveSession
+		session.Token, err = scanRefreshToken(rowWithExtra{Row: rows, extra: []any{&session.StartedAt}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan active session for user %s: %w", userID, err)
+		}
+		sessions = append(sessions, &session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan active sessions for user %s: %w", userID, err)
+	}
+	return sessions, nil
+}
+
+// GetUserSessionIPs получает адреса, с которых использовались сессии пользователя, сгруппированные по сессии и IP
+func (p *Postgres) GetUserSessionIPs(ctx context.Context, userID uuid.UUID) ([]*models.SessionIP, error) {
+	query := `SELECT family_id, ip, MIN(seen_at), MAX(seen_at), COUNT(*) FROM session_ip_history
+		WHERE user_id = $1
+		GROUP BY family_id, ip
+		ORDER BY MIN(seen_at)`
+	rows, err := p.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session ip history for user %s: %w", userID, err)
+	}
+	defer rows.Close()
+
+	var ips []*models.SessionIP
+	for rows.Next() {
+		var ip models.SessionIP
+		if err := rows.Scan(&ip.FamilyID, &ip.IP, &ip.FirstSeenAt, &ip.LastSeenAt, &ip.Uses); err != nil {
+			return nil, fmt.Errorf("failed to scan session ip history for user %s: %w", userID, err)
+		}
+		ips = append(ips, &ip)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan session ip history for user %s: %w", userID, err)
+	}
+	return ips, nil
+}
+
+// GetClientByID получает OAuth2 клиента по client_id
+func (p *Postgres) GetClientByID(ctx context.Context, clientID string) (*models.Client, error) {
+	query := `SELECT client_id, secret_hash, scopes, redirect_uris, created_at, updated_at FROM clients WHERE client_id = $1`
+	var client models.Client
+	var secretHash *string
+	err := p.pool.QueryRow(ctx, query, clientID).Scan(&client.ClientID, &secretHash, &client.Scopes, &client.RedirectURIs, &client.CreatedAt, &client.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, er.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get client %s: %w", clientID, err)
+	}
+	if secretHash != nil {
+		client.SecretHash = *secretHash
+	}
+	return &client, nil
+}
+
+// GetUserRoles получает роли пользователя вместе с их scope
+func (p *Postgres) GetUserRoles(ctx context.Context, userID uuid.UUID) ([]*models.Role, error) {
+	query := `SELECT r.name, r.scopes, r.created_at, r.updated_at FROM roles r
+		JOIN user_roles ur ON ur.role = r.name
+		WHERE ur.user_id = $1 ORDER BY r.name`
+	rows, err := p.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get roles for user %s: %w", userID, err)
+	}
+	defer rows.Close()
+
+	var roles []*models.Role
+	for rows.Next() {
+		var role models.Role
+		if err := rows.Scan(&role.Name, &role.Scopes, &role.CreatedAt, &role.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan role for user %s: %w", userID, err)
+		}
+		roles = append(roles, &role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan roles for user %s: %w", userID, err)
+	}
+	return roles, nil
+}
+
+// CreateAuthorizationCode сохраняет код авторизации
+func (p *Postgres) CreateAuthorizationCode(ctx context.Context, code *models.AuthorizationCode) error {
+	query := `INSERT INTO authorization_codes (code_hash, client_id, user_id, redirect_uri, code_challenge, scope, nonce, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := p.pool.Exec(ctx, query, code.CodeHash, code.ClientID, code.UserID, code.RedirectURI, code.CodeChallenge, code.Scope, code.Nonce, code.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create authorization code for client %s: %w", code.ClientID, err)
+	}
+	return nil
+}
+
+// ConsumeAuthorizationCode помечает код использованным и возвращает его.
+// Возвращает er.ErrNotFound, если код не существует, истёк или уже был использован.
+func (p *Postgres) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*models.AuthorizationCode, error) {
+	query := `UPDATE authorization_codes SET used_at = NOW()
+		WHERE code_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING code_hash, client_id, user_id, redirect_uri, code_challenge, scope, nonce, expires_at, used_at`
+	var code models.AuthorizationCode
+	err := p.pool.QueryRow(ctx, query, codeHash).Scan(&code.CodeHash, &code.ClientID, &code.UserID, &code.RedirectURI, &code.CodeChallenge, &code.Scope, &code.Nonce, &code.ExpiresAt, &code.UsedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, er.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to consume authorization code: %w", err)
+	}
+	return &code, nil
+}
+
+// RevokeAccessToken добавляет jti в список отозванных до момента истечения токена.
+// Заодно удаляет записи, срок действия которых уже истёк.
+func (p *Postgres) RevokeAccessToken(ctx context.Context, jti uuid.UUID, expiresAt time.Time) error {
+	query := `WITH purged AS (DELETE FROM revoked_access_tokens WHERE expires_at < NOW())
+		INSERT INTO revoked_access_tokens (jti, expires_at) VALUES ($1, $2)
+		ON CONFLICT (jti) DO UPDATE SET expires_at = GREATEST(revoked_access_tokens.expires_at, EXCLUDED.expires_at)`
+	_, err := p.pool.Exec(ctx, query, jti, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to revoke access token %s: %w", jti, err)
+	}
+	return nil
+}
+
+// IsAccessTokenRevoked проверяет, отозван ли access токен
+func (p *Postgres) IsAccessTokenRevoked(ctx context.Context, jti uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_access_tokens WHERE jti = $1)`
+	var revoked bool
+	if err := p.pool.QueryRow(ctx, query, jti).Scan(&revoked); err != nil {
+		return false, fmt.Errorf("failed to check access token %s revocation: %w", jti, err)
+	}
+	return revoked, nil
+}
+
+func scanUser(row pgx.Row) (*models.User, error) {
+	var user models.User
+	if err := row.Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.DisabledAt); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func scanCredentials(row pgx.Row) (*models.Credentials, error) {
+	var credentials models.Credentials
+	if err := row.Scan(&credentials.UserID, &credentials.Login, &credentials.PasswordHash, &credentials.CreatedAt, &credentials.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &credentials, nil
+}
+
+// rowWithExtra дочитывает колонки, выбранные после refreshTokenColumns
+type rowWithExtra struct {
+	pgx.Row
+	extra []any
+}
+
+func (r rowWithExtra) Scan(dest ...any) error {
+	return r.Row.Scan(append(dest, r.extra...)...)
+}
+
+func scanRefreshToken(row pgx.Row) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	var selector, clientID, dpopJKT *string
+	var accessJTI *uuid.UUID
+	err := row.Scan(&token.ID, &token.UserID, &selector, &token.TokenHash, &token.UserAgent, &token.IP, &token.IssuedAt, &token.ExpiresAt, &token.IsValid,
+		&token.FamilyID, &token.ParentID, &token.RotatedAt, &accessJTI, &clientID, &token.Scope, &dpopJKT,
+		&token.Device.Browser, &token.Device.BrowserMajor, &token.Device.OS, &token.Device.Type, &token.LastUsedAt, &token.RefreshCount)
+	if err != nil {
+		return nil, err
+	}
+	if clientID != nil {
+		token.ClientID = *clientID
+	}
+	if dpopJKT != nil {
+		token.DPoPJKT = *dpopJKT
+	}
+	if selector != nil {
+		token.Selector = *selector
+	}
+	if accessJTI != nil {
+		token.AccessJTI = *accessJTI
+	}
+	return &token, nil
+}
@@ -5,6 +5,7 @@ import (
 	"auth-service/internal/repository/postgres"
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -31,12 +32,36 @@ func NewRepository(cfg Config) (Repository, error) {
 
 type Repository interface {
 	GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	CreateUser(ctx context.Context, id uuid.UUID, defaultRole string) (*models.User, error)
+	SetUserDisabled(ctx context.Context, id uuid.UUID, disabled bool) (*models.User, error)
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+	GetUserRoles(ctx context.Context, userID uuid.UUID) ([]*models.Role, error)
+
+	CreateUserWithCredentials(ctx context.Context, id uuid.UUID, defaultRole string, credentials *models.Credentials) (*models.User, error)
+	GetCredentialsByLogin(ctx context.Context, login string) (*models.Credentials, error)
+	GetCredentialsByUserID(ctx context.Context, userID uuid.UUID) (*models.Credentials, error)
+	UpdatePasswordHash(ctx context.Context, userID uuid.UUID, oldHash, newHash string) error
 
 	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
-	GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	CreateSession(ctx context.Context, token *models.RefreshToken, limit models.SessionLimit) ([]*models.RefreshToken, error)
+	GetRefreshTokenBySelector(ctx context.Context, selector string) (*models.RefreshToken, error)
 	InvalidateRefreshToken(ctx context.Context, tokenHash string) error
+	RotateAndInsertRefreshToken(ctx context.Context, parentID int, token *models.RefreshToken) error
+	InvalidateTokenFamily(ctx context.Context, userID, familyID uuid.UUID) error
+	RevokeTokenFamily(ctx context.Context, userID, familyID uuid.UUID, issuedAfter time.Time) ([]*models.RefreshToken, error)
+	RevokeUserTokensExcept(ctx context.Context, userID, keepFamilyID uuid.UUID, issuedAfter time.Time) ([]*models.RefreshToken, error)
 	InvalidateAllUserTokens(ctx context.Context, userID uuid.UUID) error
 
 	GetUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]*models.RefreshToken, error)
-	GetValidUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]*models.RefreshToken, error)
+	GetLiveUserRefreshTokens(ctx context.Context, userID uuid.UUID, issuedAfter time.Time) ([]*models.RefreshToken, error)
+	GetUserActiveSessions(ctx context.Context, userID uuid.UUID) ([]*models.ActiveSession, error)
+	GetUserSessionIPs(ctx context.Context, userID uuid.UUID) ([]*models.SessionIP, error)
+
+	GetClientByID(ctx context.Context, clientID string) (*models.Client, error)
+
+	CreateAuthorizationCode(ctx context.Context, code *models.AuthorizationCode) error
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*models.AuthorizationCode, error)
+
+	RevokeAccessToken(ctx context.Context, jti uuid.UUID, expiresAt time.Time) error
+	IsAccessTokenRevoked(ctx context.Context, jti uuid.UUID) (bool, error)
 }
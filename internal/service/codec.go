@@ -0,0 +1,189 @@
+package service
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"auth-service/internal/models"
+	"auth-service/pkg/jwk"
+	"auth-service/pkg/paseto"
+)
+
+const (
+	AccessTokenFormatJWT    = "jwt"
+	AccessTokenFormatPASETO = "paseto"
+)
+
+// accessTokenCodec формат access токенов. decode проверяет только подпись;
+// exp, iss, aud и остальные claims проверяет сервис одинаково для всех форматов.
+type accessTokenCodec interface {
+	encode(claims *models.AccessTokenClaims) (string, error)
+	decode(token string) (*models.AccessTokenClaims, error)
+}
+
+func newAccessTokenCodec(cfg Config, keys *keyring) (accessTokenCodec, error) {
+	switch cfg.AccessTokenFormat {
+	case AccessTokenFormatJWT:
+		return &jwtCodec{keys: keys}, nil
+	case AccessTokenFormatPASETO:
+		return newPasetoCodec(cfg.PasetoPrivateKeyFile)
+	default:
+		return nil, fmt.Errorf("unsupported access token format: %s", cfg.AccessTokenFormat)
+	}
+}
+
+// jwtCodec access токены в формате JWT, подписанные ключами keyring
+type jwtCodec struct {
+	keys *keyring
+}
+
+func (c *jwtCodec) encode(claims *models.AccessTokenClaims) (string, error) {
+	return signJWT(c.keys, claims)
+}
+
+func (c *jwtCodec) decode(token string) (*models.AccessTokenClaims, error) {
+	parsed, err := jwt.ParseWithClaims(token, &models.AccessTokenClaims{}, c.keys.verificationKey, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := parsed.Claims.(*models.AccessTokenClaims)
+	if !ok || !parsed.Valid {
+		return nil, errors.New("unexpected jwt claims")
+	}
+	return claims, nil
+}
+
+// pasetoCodec access токены PASETO v4.public. Алгоритм определяется версией токена,
+// а не заголовком, поэтому подмена алгоритма невозможна. kid ключа передаётся в footer.
+type pasetoCodec struct {
+	kid     string
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+	footer  []byte
+}
+
+type pasetoFooter struct {
+	Kid string `json:"kid"`
+}
+
+// pasetoTimeClaims claims, которые PASETO хранит строками RFC 3339, а JWT — числами NumericDate
+var pasetoTimeClaims = []string{"exp", "nbf", "iat"}
+
+func newPasetoCodec(privateKeyFile string) (*pasetoCodec, error) {
+	if privateKeyFile == "" {
+		return nil, errors.New("PASETO_PRIVATE_KEY_FILE is required for paseto access tokens")
+	}
+	key, err := loadPrivateKey(privateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("paseto v4.public requires ed25519 key, got %T", key)
+	}
+	public := private.Public().(ed25519.PublicKey)
+	pub, err := jwk.FromPublicKey(public)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build jwk: %w", err)
+	}
+	kid, err := pub.Thumbprint()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute key thumbprint: %w", err)
+	}
+	footer, err := json.Marshal(pasetoFooter{Kid: kid})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal paseto footer: %w", err)
+	}
+	return &pasetoCodec{kid: kid, private: private, public: public, footer: footer}, nil
+}
+
+func (c *pasetoCodec) encode(claims *models.AccessTokenClaims) (string, error) {
+	payload, err := convertTimeClaims(claims, func(v interface{}) (interface{}, error) {
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("unexpected numeric date %v", v)
+		}
+		sec, err := n.Int64()
+		if err != nil {
+			return nil, err
+		}
+		return time.Unix(sec, 0).UTC().Format(time.RFC3339), nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode paseto claims: %w", err)
+	}
+	return paseto.SignV4Public(c.private, payload, c.footer)
+}
+
+func (c *pasetoCodec) decode(token string) (*models.AccessTokenClaims, error) {
+	rawFooter, err := paseto.Footer(token)
+	if err != nil {
+		return nil, err
+	}
+	var footer pasetoFooter
+	if err := json.Unmarshal(rawFooter, &footer); err != nil || footer.Kid != c.kid {
+		return nil, fmt.Errorf("unknown signing key: %q", footer.Kid)
+	}
+	payload, _, err := paseto.VerifyV4Public(c.public, token)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := unmarshalNumbers(payload, &raw); err != nil {
+		return nil, fmt.Errorf("failed to decode paseto claims: %w", err)
+	}
+	normalized, err := convertTimeClaims(raw, func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected paseto date %v", v)
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, err
+		}
+		return t.Unix(), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode paseto claims: %w", err)
+	}
+	var claims models.AccessTokenClaims
+	if err := json.Unmarshal(normalized, &claims); err != nil {
+		return nil, fmt.Errorf("failed to decode paseto claims: %w", err)
+	}
+	return &claims, nil
+}
+
+// convertTimeClaims сериализует claims в JSON, преобразуя exp, nbf и iat функцией convert
+func convertTimeClaims(claims interface{}, convert func(interface{}) (interface{}, error)) ([]byte, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := unmarshalNumbers(data, &m); err != nil {
+		return nil, err
+	}
+	for _, name := range pasetoTimeClaims {
+		v, ok := m[name]
+		if !ok {
+			continue
+		}
+		if m[name], err = convert(v); err != nil {
+			return nil, fmt.Errorf("claim %s: %w", name, err)
+		}
+	}
+	return json.Marshal(m)
+}
+
+func unmarshalNumbers(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
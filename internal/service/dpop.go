@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"auth-service/pkg/dpop"
+	"auth-service/pkg/er"
+)
+
+const (
+	TokenTypeBearer = "Bearer"
+	TokenTypeDPoP   = "DPoP"
+)
+
+// VerifyDPoPProof проверяет DPoP proof запроса method path и возвращает отпечаток ключа клиента.
+// htu сверяется с ISSUER_URL + path, поэтому результат не зависит от прокси перед сервисом.
+// accessToken передаётся при обращении к защищённым маршрутам и сверяется с ath proof.
+func (s *Service) VerifyDPoPProof(ctx context.Context, proof, method, path, accessToken string) (string, error) {
+	now := time.Now()
+	p, err := dpop.Verify(proof, dpop.Request{
+		Method:      method,
+		URL:         s.issuer + path,
+		AccessToken: accessToken,
+	}, now, s.dpopMaxAge, s.leeway)
+	if err != nil {
+		zap.S().Infof("rejected dpop proof: %v", err)
+		return "", er.ErrInvalidDPoPProof
+	}
+	// jti уникален в пределах ключа; запись живёт, пока proof с таким iat ещё может быть принят
+	if err := s.dpopReplay.Use(p.JKT+":"+p.JTI, p.IssuedAt.Add(s.dpopMaxAge+2*s.leeway), now); err != nil {
+		zap.S().Warnf("dpop proof %s replayed for key %s", p.JTI, p.JKT)
+		return "", er.ErrInvalidDPoPProof
+	}
+	return p.JKT, nil
+}
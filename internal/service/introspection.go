@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"auth-service/internal/models"
+	"auth-service/pkg/er"
+)
+
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// AuthenticateIntrospectionClient проверяет учётные данные сервиса, вызывающего introspection.
+// Клиенту должен быть выдан scope introspect.
+func (s *Service) AuthenticateIntrospectionClient(ctx context.Context, clientID, secret string) error {
+	client, err := s.AuthenticateClient(ctx, clientID, secret)
+	if err != nil {
+		return err
+	}
+	if !containsScope(client.Scopes, ScopeIntrospect) {
+		return er.ErrInvalidClient
+	}
+	return nil
+}
+
+// AuthenticateRevocationClient проверяет клиента, вызывающего отзыв токена (RFC 7009, 2.1).
+// Конфиденциальный клиент аутентифицируется секретом, публичный передаёт только client_id.
+func (s *Service) AuthenticateRevocationClient(ctx context.Context, clientID, secret string) error {
+	client, err := s.repo.GetClientByID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, er.ErrNotFound) {
+			return er.ErrInvalidClient
+		}
+		return fmt.Errorf("failed to get client: %w", err)
+	}
+	if client.IsPublic() {
+		if secret != "" {
+			return er.ErrInvalidClient
+		}
+		return nil
+	}
+	_, err = s.AuthenticateClient(ctx, clientID, secret)
+	return err
+}
+
+// Introspect возвращает состояние access или refresh токена по RFC 7662.
+// Недействительный токен любого вида даёт ответ {"active": false} без уточнения причины.
+func (s *Service) Introspect(ctx context.Context, token, tokenTypeHint string) (*Introspection, error) {
+	lookups := []func(context.Context, string) (*Introspection, error){s.introspectAccessToken, s.introspectRefreshToken}
+	if tokenTypeHint == TokenTypeHintRefreshToken {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+	for _, lookup := range lookups {
+		result, err := lookup(ctx, token)
+		if err == nil {
+			return result, nil
+		}
+		if !errors.Is(err, er.ErrInvalidToken) {
+			return nil, err
+		}
+	}
+	return &Introspection{Active: false}, nil
+}
+
+func (s *Service) introspectAccessToken(ctx context.Context, token string) (*Introspection, error) {
+	claims, err := s.validateAccessToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	result := &Introspection{
+		Active:    true,
+		TokenType: TokenTypeHintAccessToken,
+		Sub:       claims.UserID.String(),
+		ClientID:  claims.ClientID,
+		Scope:     claims.Scope,
+	}
+	if claims.UserID == uuid.Nil {
+		result.Sub = claims.Subject
+	}
+	if claims.SessionID != uuid.Nil {
+		result.SessionID = claims.SessionID.String()
+	}
+	if claims.ExpiresAt != nil {
+		result.Exp = claims.ExpiresAt.Unix()
+	}
+	result.Cnf = claims.Confirmation
+	if claims.IssuedAt != nil {
+		result.Iat = claims.IssuedAt.Unix()
+	}
+	return result, nil
+}
+
+func (s *Service) introspectRefreshToken(ctx context.Context, token string) (*Introspection, error) {
+	refreshToken, err := s.findRefreshToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if !refreshToken.IsValid || refreshToken.ExpiresAt.Before(time.Now()) {
+		return nil, er.ErrInvalidToken
+	}
+	// scope считается так же, как при обновлении пары: по ролям пользователя с учётом scope, запрошенного клиентом
+	_, scope, err := s.userGrants(ctx, refreshToken.UserID, refreshToken.Scope, refreshToken.ClientID != "")
+	if err != nil {
+		return nil, err
+	}
+	result := &Introspection{
+		Active:    true,
+		TokenType: TokenTypeHintRefreshToken,
+		Sub:       refreshToken.UserID.String(),
+		Exp:       refreshToken.ExpiresAt.Unix(),
+		Iat:       refreshToken.IssuedAt.Unix(),
+		ClientID:  refreshToken.ClientID,
+		Scope:     scope,
+		SessionID: refreshToken.FamilyID.String(),
+	}
+	if refreshToken.DPoPJKT != "" {
+		result.Cnf = &models.Confirmation{JKT: refreshToken.DPoPJKT}
+	}
+	return result, nil
+}
+
+// Revoke отзывает один access или refresh токен, выданный клиенту clientID (RFC 7009).
+// Отзыв refresh токена завершает его сессию вместе с выпущенным в паре access токеном.
+// Недействительный токен и токен другого клиента не отзываются и не считаются ошибкой.
+func (s *Service) Revoke(ctx context.Context, clientID, token, tokenTypeHint string) error {
+	revokers := []func(ctx context.Context, clientID, token string) error{s.revokeAccessToken, s.revokeRefreshToken}
+	if tokenTypeHint == TokenTypeHintRefreshToken {
+		revokers[0], revokers[1] = revokers[1], revokers[0]
+	}
+	for _, revoke := range revokers {
+		err := revoke(ctx, clientID, token)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, er.ErrInvalidToken) {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeAccessToken принимает и истёкший токен: в пределах ACCESS_EXPIRED_GRACE им ещё можно обновить пару
+func (s *Service) revokeAccessToken(ctx context.Context, clientID, token string) error {
+	claims, err := s.parseExpiredAccessToken(token)
+	if err != nil {
+		return er.ErrInvalidToken
+	}
+	if claims.ClientID != clientID {
+		zap.S().Infof("client %s tried to revoke an access token issued to %q", clientID, claims.ClientID)
+		return er.ErrInvalidToken
+	}
+	jti := claims.JTI()
+	if jti == uuid.Nil {
+		return er.ErrInvalidToken
+	}
+	if err := s.repo.RevokeAccessToken(ctx, jti, s.revokedUntil(claims.Expiry())); err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) revokeRefreshToken(ctx context.Context, clientID, token string) error {
+	refreshToken, err := s.findRefreshToken(ctx, token)
+	if err != nil {
+		return err
+	}
+	if refreshToken.ClientID != clientID {
+		zap.S().Infof("client %s tried to revoke a refresh token issued to %q", clientID, refreshToken.ClientID)
+		return er.ErrInvalidToken
+	}
+	if !refreshToken.IsValid {
+		return nil
+	}
+	if err := s.repo.InvalidateRefreshToken(ctx, refreshToken.TokenHash); err != nil && !errors.Is(err, er.ErrNotFound) {
+		return fmt.Errorf("failed to invalidate refresh token: %w", err)
+	}
+	return s.revokePairedAccessTokens(ctx, []*models.RefreshToken{refreshToken})
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"auth-service/pkg/dpop"
+	"auth-service/pkg/er"
+)
+
+const (
+	// ScopeTokensIssue разрешает доверенному клиенту выдавать токены пользователям через /api/tokens/{guid}
+	ScopeTokensIssue = "tokens:issue"
+	// issuanceAssertionMaxLifetime максимальный срок действия подписанного утверждения (exp - iat)
+	issuanceAssertionMaxLifetime = 5 * time.Minute
+)
+
+// issuanceAssertionVerifier проверяет утверждения, которыми identity front-end подтверждает вход пользователя
+type issuanceAssertionVerifier struct {
+	issuer  string
+	key     interface{}
+	methods []string
+	replay  *dpop.ReplayCache
+}
+
+func newIssuanceAssertionVerifier(cfg Config) (*issuanceAssertionVerifier, error) {
+	if cfg.IssuanceAssertionKeyFile == "" {
+		return nil, nil
+	}
+	if cfg.IssuanceAssertionIssuer == "" {
+		return nil, errors.New("issuer of issuance assertions is required when assertion key is set")
+	}
+	key, err := loadPublicKey(cfg.IssuanceAssertionKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	methods, err := publicKeyMethods(key)
+	if err != nil {
+		return nil, err
+	}
+	return &issuanceAssertionVerifier{
+		issuer:  cfg.IssuanceAssertionIssuer,
+		key:     key,
+		methods: methods,
+		replay:  dpop.NewReplayCache(),
+	}, nil
+}
+
+// IssuanceDevMode сообщает, разрешена ли выдача токенов без аутентификации вызывающего (ISSUANCE_DEV_MODE)
+func (s *Service) IssuanceDevMode() bool {
+	return s.issuanceDevMode
+}
+
+// AuthenticateIssuanceClient проверяет учётные данные доверенного клиента, выдающего токены пользователям.
+// Клиенту должен быть выдан scope tokens:issue.
+func (s *Service) AuthenticateIssuanceClient(ctx context.Context, clientID, secret string) error {
+	client, err := s.AuthenticateClient(ctx, clientID, secret)
+	if err != nil {
+		return err
+	}
+	if !containsScope(client.Scopes, ScopeTokensIssue) {
+		return er.ErrInvalidClient
+	}
+	return nil
+}
+
+// VerifyIssuanceAssertion проверяет подписанное identity front-end утверждение о входе пользователя userID:
+// JWT с iss = ISSUANCE_ASSERTION_ISSUER, sub = GUID пользователя, aud = ISSUER_URL, сроком действия
+// не более issuanceAssertionMaxLifetime и уникальным jti. Каждое утверждение принимается один раз.
+func (s *Service) VerifyIssuanceAssertion(ctx context.Context, assertion string, userID uuid.UUID) error {
+	v := s.issuanceAssertions
+	if v == nil {
+		return er.ErrInvalidToken
+	}
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(assertion, &claims, func(*jwt.Token) (interface{}, error) {
+		return v.key, nil
+	},
+		jwt.WithValidMethods(v.methods),
+		jwt.WithIssuer(v.issuer),
+		jwt.WithSubject(userID.String()),
+		jwt.WithAudience(s.issuer),
+		jwt.WithLeeway(s.leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		zap.S().Infof("invalid issuance assertion: %s", err)
+		return er.ErrInvalidToken
+	}
+	if claims.IssuedAt == nil || claims.ID == "" || claims.ExpiresAt.Sub(claims.IssuedAt.Time) > issuanceAssertionMaxLifetime {
+		return er.ErrInvalidToken
+	}
+	if err := v.replay.Use(claims.ID, claims.ExpiresAt.Add(s.leeway), time.Now()); err != nil {
+		zap.S().Warnf("issuance assertion %s for user %s replayed", claims.ID, userID)
+		return er.ErrInvalidToken
+	}
+	return nil
+}
+
+func loadPublicKey(path string) (interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key file %s: %w", path, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("no PUBLIC KEY pem block found in %s", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// publicKeyMethods возвращает алгоритмы JWT, которые можно проверить ключом key
+func publicKeyMethods(key interface{}) ([]string, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}, nil
+	case *ecdsa.PublicKey:
+		for _, m := range []*jwt.SigningMethodECDSA{jwt.SigningMethodES256, jwt.SigningMethodES384, jwt.SigningMethodES512} {
+			if m.CurveBits == k.Curve.Params().BitSize {
+				return []string{m.Alg()}, nil
+			}
+		}
+		return nil, fmt.Errorf("unsupported ecdsa curve %s", k.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return []string{jwt.SigningMethodEdDSA.Alg()}, nil
+	}
+	return nil, fmt.Errorf("unsupported public key type %T", key)
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+
+	"auth-service/pkg/jwk"
+)
+
+var errNoActiveSigningKey = errors.New("no active signing key")
+
+// keyringEntry описание ключа в файле JWT_KEYRING_FILE
+type keyringEntry struct {
+	Kid            string     `json:"kid"`
+	Alg            string     `json:"alg"`
+	SecretFile     string     `json:"secret_file"`
+	PrivateKeyFile string     `json:"private_key_file"`
+	ActiveFrom     time.Time  `json:"active_from"`
+	VerifyUntil    *time.Time `json:"verify_until"`
+}
+
+// keyring набор ключей подписи: один активный ключ и ключи, по которым ещё проверяются выданные токены.
+// Активным считается ключ с самым поздним active_from, который уже наступил, поэтому
+// запланированная смена ключа происходит без перезапуска сервиса.
+type keyring struct {
+	// keys отсортированы по activeFrom
+	keys []*signingKey
+	byID map[string]*signingKey
+}
+
+func newKeyring(cfg Config) (*keyring, error) {
+	var keys []*signingKey
+
+	if cfg.JwtKeyringFile != "" {
+		entries, err := readKeyringFile(cfg.JwtKeyringFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			key, err := newKeyringKey(e)
+			if err != nil {
+				return nil, fmt.Errorf("keyring key %q: %w", e.Kid, err)
+			}
+			keys = append(keys, key)
+		}
+		// Старый JWT_SECRET остаётся только для проверки токенов, выпущенных до перехода на keyring
+		// алгоритмом JWT_SIGNING_METHOD; секрет подходит только для HS* алгоритмов
+		if cfg.JwtSecret != "" {
+			if _, ok := jwt.GetSigningMethod(cfg.JwtSigningMethod).(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("legacy secret: unsupported signing method %q, HS256/HS384/HS512 expected", cfg.JwtSigningMethod)
+			}
+			legacy, err := newSigningKey(cfg.JwtSigningMethod, cfg.JwtSecret, "")
+			if err != nil {
+				return nil, fmt.Errorf("legacy secret: %w", err)
+			}
+			legacy.verifyOnly = true
+			keys = append(keys, legacy)
+		}
+	} else {
+		key, err := newSigningKey(cfg.JwtSigningMethod, cfg.JwtSecret, cfg.JwtPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].activeFrom.Before(keys[j].activeFrom)
+	})
+
+	kr := &keyring{keys: keys, byID: make(map[string]*signingKey, len(keys))}
+	for _, k := range keys {
+		if _, ok := kr.byID[k.kid]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", k.kid)
+		}
+		kr.byID[k.kid] = k
+	}
+	if _, err := kr.signing(time.Now()); err != nil {
+		return nil, err
+	}
+	kr.logSchedule()
+	return kr, nil
+}
+
+// newIDTokenKeyring возвращает ключи подписи id_token. id_token проверяют клиенты по JWKS, поэтому он подписывается
+// только асимметричным ключом: ключом из ID_TOKEN_PRIVATE_KEY_FILE, ключами access токенов, если среди них нет HMAC,
+// либо временным ключом ES256, который создаётся при старте и меняется при каждом перезапуске.
+func newIDTokenKeyring(cfg Config, accessKeys *keyring) (*keyring, error) {
+	if cfg.IDTokenPrivateKeyFile != "" {
+		method := jwt.GetSigningMethod(cfg.IDTokenSigningMethod)
+		if _, ok := method.(*jwt.SigningMethodHMAC); ok || method == nil || method == jwt.SigningMethodNone {
+			return nil, fmt.Errorf("unsupported id_token signing method: %s", cfg.IDTokenSigningMethod)
+		}
+		key, err := newSigningKey(cfg.IDTokenSigningMethod, "", cfg.IDTokenPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return newSingleKeyring(key), nil
+	}
+	if !accessKeys.hasSymmetricSigningKeys() {
+		return accessKeys, nil
+	}
+
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate id_token key: %w", err)
+	}
+	key, err := newAsymmetricKey(jwt.SigningMethodES256, private)
+	if err != nil {
+		return nil, err
+	}
+	zap.S().Warnf("access tokens are signed with a shared secret, id_token is signed with ephemeral key %q (ES256); "+
+		"set ID_TOKEN_PRIVATE_KEY_FILE to keep the key across restarts and replicas", key.kid)
+	return newSingleKeyring(key), nil
+}
+
+func newSingleKeyring(key *signingKey) *keyring {
+	return &keyring{keys: []*signingKey{key}, byID: map[string]*signingKey{key.kid: key}}
+}
+
+func readKeyringFile(path string) ([]keyringEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring file %s: %w", path, err)
+	}
+	var entries []keyringEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring file %s: %w", path, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("keyring file %s has no keys", path)
+	}
+	return entries, nil
+}
+
+func newKeyringKey(e keyringEntry) (*signingKey, error) {
+	var secret string
+	if e.SecretFile != "" {
+		data, err := os.ReadFile(e.SecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret file %s: %w", e.SecretFile, err)
+		}
+		secret = strings.TrimSpace(string(data))
+	}
+
+	key, err := newSigningKey(e.Alg, secret, e.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if e.Kid != "" {
+		key.kid = e.Kid
+	}
+	if key.kid == "" {
+		return nil, fmt.Errorf("kid is required for signing method %s", e.Alg)
+	}
+	key.activeFrom = e.ActiveFrom
+	if e.VerifyUntil != nil {
+		if !e.VerifyUntil.After(e.ActiveFrom) {
+			return nil, fmt.Errorf("verify_until must be after active_from")
+		}
+		key.verifyUntil = *e.VerifyUntil
+	}
+	return key, nil
+}
+
+// signing возвращает ключ, которым нужно подписывать токены в момент now
+func (kr *keyring) signing(now time.Time) (*signingKey, error) {
+	for i := len(kr.keys) - 1; i >= 0; i-- {
+		k := kr.keys[i]
+		if k.verifyOnly || k.activeFrom.After(now) || !k.canVerify(now) {
+			continue
+		}
+		return k, nil
+	}
+	return nil, errNoActiveSigningKey
+}
+
+// verification возвращает ключ для проверки токена по kid из заголовка
+func (kr *keyring) verification(kid string, now time.Time) (*signingKey, bool) {
+	k, ok := kr.byID[kid]
+	if !ok || !k.canVerify(now) {
+		return nil, false
+	}
+	return k, true
+}
+
+// verificationKey выбирает ключ проверки JWT по kid из заголовка и сверяет алгоритм с ключом
+func (kr *keyring) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := kr.verification(kid, time.Now())
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// jwks возвращает публичные ключи, включая ещё не активные, чтобы потребители получили их заранее
+func (kr *keyring) jwks(now time.Time) jwk.Set {
+	set := jwk.Set{Keys: []jwk.Key{}}
+	for _, k := range kr.keys {
+		if !k.canVerify(now) {
+			continue
+		}
+		if pub, ok := k.jwk(); ok {
+			set.Keys = append(set.Keys, *pub)
+		}
+	}
+	return set
+}
+
+// hasSymmetricSigningKeys сообщает, подписывает ли keyring (сейчас или по расписанию) токены HMAC ключом
+func (kr *keyring) hasSymmetricSigningKeys() bool {
+	for _, k := range kr.keys {
+		if !k.verifyOnly && k.symmetric() {
+			return true
+		}
+	}
+	return false
+}
+
+// algorithms возвращает алгоритмы ключей, которыми сервис подписывает или будет подписывать токены
+func (kr *keyring) algorithms() []string {
+	var algs []string
+	seen := make(map[string]bool)
+	for _, k := range kr.keys {
+		if k.verifyOnly || seen[k.method.Alg()] {
+			continue
+		}
+		seen[k.method.Alg()] = true
+		algs = append(algs, k.method.Alg())
+	}
+	return algs
+}
+
+func (kr *keyring) logSchedule() {
+	for _, k := range kr.keys {
+		switch {
+		case k.verifyOnly:
+			zap.S().Infof("signing key %q (%s): verification only", k.kid, k.method.Alg())
+		case k.verifyUntil.IsZero():
+			zap.S().Infof("signing key %q (%s): active from %s", k.kid, k.method.Alg(), k.activeFrom.Format(time.RFC3339))
+		default:
+			zap.S().Infof("signing key %q (%s): active from %s, verified until %s", k.kid, k.method.Alg(),
+				k.activeFrom.Format(time.RFC3339), k.verifyUntil.Format(time.RFC3339))
+		}
+	}
+}
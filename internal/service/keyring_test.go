@@ -0,0 +1,258 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writePrivateKey(t *testing.T, key crypto.Signer) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newECKeyFile(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePrivateKey(t, key)
+}
+
+func writeKeyringFile(t *testing.T, entries []keyringEntry) string {
+	t.Helper()
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestKeyringSchedule(t *testing.T) {
+	now := time.Now()
+	retiredUntil := now.Add(-time.Minute)
+	previousUntil := now.Add(time.Hour)
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("old-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := writeKeyringFile(t, []keyringEntry{
+		{Kid: "next", Alg: "ES256", PrivateKeyFile: newECKeyFile(t), ActiveFrom: now.Add(time.Hour)},
+		{Kid: "retired", Alg: "ES256", PrivateKeyFile: newECKeyFile(t), ActiveFrom: now.Add(-72 * time.Hour), VerifyUntil: &retiredUntil},
+		{Kid: "current", Alg: "ES256", PrivateKeyFile: newECKeyFile(t), ActiveFrom: now.Add(-time.Hour)},
+		{Kid: "previous", Alg: "HS256", SecretFile: secret, ActiveFrom: now.Add(-48 * time.Hour), VerifyUntil: &previousUntil},
+	})
+	kr, err := newKeyring(Config{JwtKeyringFile: path, JwtSecret: "legacy", JwtSigningMethod: "HS384"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signing, err := kr.signing(now)
+	if err != nil || signing.kid != "current" {
+		t.Fatalf("signing(now) = %v, %v; want current", signing, err)
+	}
+	if k, _ := kr.signing(now.Add(2 * time.Hour)); k == nil || k.kid != "next" {
+		t.Fatalf("signing(+2h) = %v, want next", k)
+	}
+	if k, _ := kr.signing(now.Add(-30 * time.Hour)); k == nil || k.kid != "previous" {
+		t.Fatalf("signing(-30h) = %v, want previous", k)
+	}
+	if k, _ := kr.signing(now.Add(-50 * time.Hour)); k == nil || k.kid != "retired" {
+		t.Fatalf("signing(-50h) = %v, want retired", k)
+	}
+
+	for kid, want := range map[string]bool{"next": true, "current": true, "previous": true, "retired": false, "unknown": false} {
+		if _, ok := kr.verification(kid, now); ok != want {
+			t.Errorf("verification(%q) = %v, want %v", kid, ok, want)
+		}
+	}
+	if k, ok := kr.verification("", now); !ok || !k.verifyOnly || k.method != jwt.SigningMethodHS384 {
+		t.Error("legacy JWT_SECRET must stay available for tokens without kid with JWT_SIGNING_METHOD")
+	}
+	if string(kr.byID["previous"].private.([]byte)) != "old-secret" {
+		t.Error("secret file must be trimmed")
+	}
+
+	var kids []string
+	for _, k := range kr.jwks(now).Keys {
+		kids = append(kids, k.Kid)
+	}
+	if len(kids) != 2 || kids[0] != "current" || kids[1] != "next" {
+		t.Errorf("jwks kids = %v, want [current next]: HMAC and retired keys are not published", kids)
+	}
+	if algs := kr.algorithms(); len(algs) != 2 || algs[0] != "ES256" || algs[1] != "HS256" {
+		t.Errorf("algorithms() = %v, want [ES256 HS256]", algs)
+	}
+	if !kr.hasSymmetricSigningKeys() {
+		t.Error("keyring with a scheduled HMAC key signs with a shared secret")
+	}
+}
+
+func TestKeyringVerificationKey(t *testing.T) {
+	kr, err := newKeyring(Config{JwtSigningMethod: "ES256", JwtPrivateKeyFile: newECKeyFile(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := kr.signing(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.kid == "" {
+		t.Fatal("asymmetric key must get its thumbprint as kid")
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.RegisteredClaims{Subject: "a"})
+	token.Header["kid"] = key.kid
+	signed, err := token.SignedString(key.private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(signed, kr.verificationKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// подмена алгоритма при том же kid отклоняется до проверки подписи
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "a"})
+	forged.Header["kid"] = key.kid
+	forgedSigned, err := forged.SignedString([]byte("public key bytes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(forgedSigned, kr.verificationKey); err == nil {
+		t.Fatal("token with another alg must be rejected")
+	}
+	unknown := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.RegisteredClaims{})
+	unknown.Header["kid"] = "unknown"
+	unknownSigned, _ := unknown.SignedString(key.private)
+	if _, err := jwt.Parse(unknownSigned, kr.verificationKey); err == nil {
+		t.Fatal("token with unknown kid must be rejected")
+	}
+}
+
+func TestNewKeyringErrors(t *testing.T) {
+	ecKey := newECKeyFile(t)
+	future := time.Now().Add(time.Hour)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"none alg", Config{JwtSigningMethod: "none"}},
+		{"unknown alg", Config{JwtSigningMethod: "HS1"}},
+		{"hmac without secret", Config{JwtSigningMethod: "HS512"}},
+		{"asymmetric without key", Config{JwtSigningMethod: "ES256"}},
+		{"key of another type", Config{JwtSigningMethod: "RS256", JwtPrivateKeyFile: ecKey}},
+		{"curve of another size", Config{JwtSigningMethod: "ES384", JwtPrivateKeyFile: ecKey}},
+		{"rsa key for ecdsa", Config{JwtSigningMethod: "ES256", JwtPrivateKeyFile: writePrivateKey(t, rsaKey)}},
+		{"duplicate kid", Config{JwtKeyringFile: writeKeyringFile(t, []keyringEntry{
+			{Kid: "a", Alg: "ES256", PrivateKeyFile: ecKey},
+			{Kid: "a", Alg: "ES256", PrivateKeyFile: newECKeyFile(t)},
+		})}},
+		{"only future keys", Config{JwtKeyringFile: writeKeyringFile(t, []keyringEntry{
+			{Kid: "a", Alg: "ES256", PrivateKeyFile: ecKey, ActiveFrom: future},
+		})}},
+		{"hmac without kid", Config{JwtKeyringFile: writeKeyringFile(t, []keyringEntry{
+			{Alg: "HS256", SecretFile: ecKey},
+		})}},
+		{"verify_until before active_from", Config{JwtKeyringFile: writeKeyringFile(t, []keyringEntry{
+			{Kid: "a", Alg: "ES256", PrivateKeyFile: ecKey, ActiveFrom: future, VerifyUntil: &time.Time{}},
+		})}},
+		{"empty keyring", Config{JwtKeyringFile: writeKeyringFile(t, []keyringEntry{})}},
+		{"legacy secret with asymmetric alg", Config{JwtSigningMethod: "ES256", JwtSecret: "legacy", JwtKeyringFile: writeKeyringFile(t, []keyringEntry{
+			{Kid: "a", Alg: "ES256", PrivateKeyFile: ecKey},
+		})}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newKeyring(tt.cfg); err == nil {
+				t.Fatal("newKeyring() error = nil")
+			}
+		})
+	}
+}
+
+func TestIDTokenKeyring(t *testing.T) {
+	hmacKeys, err := newKeyring(Config{JwtSigningMethod: "HS512", JwtSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKeys, err := newKeyring(Config{JwtSigningMethod: "ES256", JwtPrivateKeyFile: newECKeyFile(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("asymmetric access keys are reused", func(t *testing.T) {
+		kr, err := newIDTokenKeyring(Config{}, ecKeys)
+		if err != nil || kr != ecKeys {
+			t.Fatalf("newIDTokenKeyring() = %v, %v; want access keyring", kr, err)
+		}
+	})
+
+	t.Run("hmac access keys get an ephemeral key", func(t *testing.T) {
+		kr, err := newIDTokenKeyring(Config{}, hmacKeys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := kr.signing(time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key.method != jwt.SigningMethodES256 {
+			t.Fatalf("ephemeral key alg = %s, want ES256", key.method.Alg())
+		}
+		if set := kr.jwks(time.Now()); len(set.Keys) != 1 || set.Keys[0].Kid != key.kid {
+			t.Fatalf("ephemeral key must be published in JWKS: %+v", set)
+		}
+		if _, ok := hmacKeys.verification(key.kid, time.Now()); ok {
+			t.Fatal("id_token key must not verify access tokens")
+		}
+	})
+
+	t.Run("dedicated key file", func(t *testing.T) {
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kr, err := newIDTokenKeyring(Config{IDTokenSigningMethod: "RS256", IDTokenPrivateKeyFile: writePrivateKey(t, rsaKey)}, hmacKeys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if algs := kr.algorithms(); len(algs) != 1 || algs[0] != "RS256" {
+			t.Fatalf("algorithms() = %v, want [RS256]", algs)
+		}
+	})
+
+	t.Run("dedicated key file with hmac", func(t *testing.T) {
+		if _, err := newIDTokenKeyring(Config{IDTokenSigningMethod: "HS256", IDTokenPrivateKeyFile: newECKeyFile(t)}, ecKeys); err == nil {
+			t.Fatal("HMAC id_token signing method must be rejected")
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"auth-service/pkg/jwk"
+)
+
+// signingKey ключ, которым подписываются и проверяются access токены
+type signingKey struct {
+	kid    string
+	method jwt.SigningMethod
+	// private []byte для HMAC, иначе *rsa.PrivateKey, *ecdsa.PrivateKey или ed25519.PrivateKey
+	private interface{}
+	// public []byte для HMAC, иначе соответствующий публичный ключ
+	public interface{}
+
+	// activeFrom момент, с которого ключ используется для подписи
+	activeFrom time.Time
+	// verifyUntil момент, после которого токены с этим ключом не принимаются (нулевое значение — без ограничения)
+	verifyUntil time.Time
+	// verifyOnly ключ никогда не используется для подписи
+	verifyOnly bool
+}
+
+func newSigningKey(alg, secret, privateKeyFile string) (*signingKey, error) {
+	method := jwt.GetSigningMethod(alg)
+	if method == nil || method == jwt.SigningMethodNone {
+		return nil, fmt.Errorf("unsupported signing method: %s", alg)
+	}
+
+	if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		if secret == "" {
+			return nil, fmt.Errorf("secret is required for signing method %s", alg)
+		}
+		return &signingKey{method: method, private: []byte(secret), public: []byte(secret)}, nil
+	}
+
+	if privateKeyFile == "" {
+		return nil, fmt.Errorf("private key file is required for signing method %s", alg)
+	}
+	private, err := loadPrivateKey(privateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return newAsymmetricKey(method, private)
+}
+
+// newAsymmetricKey создаёт ключ подписи из приватного ключа; kid — отпечаток публичной части (RFC 7638)
+func newAsymmetricKey(method jwt.SigningMethod, private crypto.Signer) (*signingKey, error) {
+	if err := checkKeyMatchesMethod(private, method); err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{method: method, private: private, public: private.Public()}
+	pub, err := jwk.FromPublicKey(key.public)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build jwk: %w", err)
+	}
+	key.kid, err = pub.Thumbprint()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute key thumbprint: %w", err)
+	}
+	return key, nil
+}
+
+// symmetric сообщает, что ключ HMAC и токены с ним не могут проверить сторонние клиенты
+func (k *signingKey) symmetric() bool {
+	_, ok := k.method.(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+// canVerify сообщает, принимаются ли ещё токены, подписанные этим ключом
+func (k *signingKey) canVerify(now time.Time) bool {
+	return k.verifyUntil.IsZero() || now.Before(k.verifyUntil)
+}
+
+// jwk возвращает публичную часть ключа; для HMAC ключей публичной части нет
+func (k *signingKey) jwk() (*jwk.Key, bool) {
+	if k.symmetric() {
+		return nil, false
+	}
+	pub, err := jwk.FromPublicKey(k.public)
+	if err != nil {
+		return nil, false
+	}
+	pub.Kid = k.kid
+	pub.Use = "sig"
+	pub.Alg = k.method.Alg()
+	return pub, true
+}
+
+func loadPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key file %s: %w", path, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no pem block found in %s", path)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported pem block type %q in %s", block.Type, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T in %s", key, path)
+	}
+	return signer, nil
+}
+
+func checkKeyMatchesMethod(key crypto.Signer, method jwt.SigningMethod) error {
+	switch m := method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if _, ok := key.(*rsa.PrivateKey); !ok {
+			return fmt.Errorf("signing method %s requires rsa key, got %T", method.Alg(), key)
+		}
+	case *jwt.SigningMethodECDSA:
+		k, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return fmt.Errorf("signing method %s requires ecdsa key, got %T", method.Alg(), key)
+		}
+		if k.Curve.Params().BitSize != m.CurveBits {
+			return fmt.Errorf("signing method %s requires %d-bit curve, got %s", method.Alg(), m.CurveBits, k.Curve.Params().Name)
+		}
+	case *jwt.SigningMethodEd25519:
+		if _, ok := key.(ed25519.PrivateKey); !ok {
+			return fmt.Errorf("signing method %s requires ed25519 key, got %T", method.Alg(), key)
+		}
+	default:
+		return errors.New("unsupported signing method: " + method.Alg())
+	}
+	return nil
+}
@@ -1,9 +1,124 @@
 package service
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"auth-service/internal/models"
+)
+
+const (
+	EventIPChanged         = "ip_changed"
+	EventRefreshTokenReuse = "refresh_token_reuse"
+)
 
 type WebhookRequest struct {
-	NewIP  string    `json:"new_ip"`
-	UserID uuid.UUID `json:"guid"`
-	Ts     int64     `json:"ts"`
+	Event    string     `json:"event,omitempty"`
+	NewIP    string     `json:"new_ip"`
+	UserID   uuid.UUID  `json:"guid"`
+	FamilyID *uuid.UUID `json:"family_id,omitempty"`
+	Ts       int64      `json:"ts"`
+}
+
+// Introspection ответ introspection endpoint (RFC 7662)
+type Introspection struct {
+	Active    bool                 `json:"active"`
+	TokenType string               `json:"token_type,omitempty"`
+	Sub       string               `json:"sub,omitempty"`
+	Exp       int64                `json:"exp,omitempty"`
+	Iat       int64                `json:"iat,omitempty"`
+	ClientID  string               `json:"client_id,omitempty"`
+	Scope     string               `json:"scope,omitempty"`
+	SessionID string               `json:"sid,omitempty"`
+	Cnf       *models.Confirmation `json:"cnf,omitempty"`
+}
+
+// TokenPair пара токенов сессии пользователя и id_token
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	IDToken      string
+	// TokenType Bearer или DPoP для токенов, привязанных к ключу клиента
+	TokenType string
+	// Scope выданный в access токене scope
+	Scope string
+}
+
+// IssuedToken результат выдачи токенов через OAuth2 token endpoint
+type IssuedToken struct {
+	AccessToken  string
+	RefreshToken string
+	IDToken      string
+	ExpiresIn    int64
+	Scope        string
+}
+
+// AuthorizeRequest параметры запроса авторизации (RFC 6749, 4.1.1; RFC 7636, 4.3)
+type AuthorizeRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+	UserID              uuid.UUID
+}
+
+// CodeExchangeRequest параметры обмена кода авторизации на токены (RFC 6749, 4.1.3; RFC 7636, 4.5)
+type CodeExchangeRequest struct {
+	ClientID     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	UserAgent    string
+	IP           string
+}
+
+// UserInfo ответ OpenID Connect userinfo endpoint
+type UserInfo struct {
+	Sub       string `json:"sub"`
+	GUID      string `json:"guid"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// OpenIDConfiguration документ OpenID Connect Discovery
+type OpenIDConfiguration struct {
+	Issuer                                string   `json:"issuer"`
+	AuthorizationEndpoint                 string   `json:"authorization_endpoint"`
+	TokenEndpoint                         string   `json:"token_endpoint"`
+	UserinfoEndpoint                      string   `json:"userinfo_endpoint"`
+	JwksURI                               string   `json:"jwks_uri"`
+	IntrospectionEndpoint                 string   `json:"introspection_endpoint"`
+	RevocationEndpoint                    string   `json:"revocation_endpoint"`
+	ResponseTypesSupported                []string `json:"response_types_supported"`
+	GrantTypesSupported                   []string `json:"grant_types_supported"`
+	SubjectTypesSupported                 []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported      []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                       []string `json:"scopes_supported"`
+	ClaimsSupported                       []string `json:"claims_supported"`
+	CodeChallengeMethodsSupported         []string `json:"code_challenge_methods_supported"`
+	DPoPSigningAlgValuesSupported         []string `json:"dpop_signing_alg_values_supported"`
+	TLSClientCertificateBoundAccessTokens bool     `json:"tls_client_certificate_bound_access_tokens"`
+	TokenEndpointAuthMethodsSupported     []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// Session активная сессия пользователя (цепочка refresh токенов)
+type Session struct {
+	ID           uuid.UUID     `json:"id"`
+	UserAgent    string        `json:"user_agent"`
+	Device       models.Device `json:"device"`
+	IP           string        `json:"ip"`
+	ClientID     string        `json:"client_id,omitempty"`
+	IssuedAt     time.Time     `json:"issued_at"`
+	LastUsedAt   time.Time     `json:"last_used_at"`
+	RefreshCount int           `json:"refresh_count"`
+	ExpiresAt    time.Time     `json:"expires_at"`
+	Current      bool          `json:"current"`
+	// IPHistory адреса, с которых выдавались и обновлялись токены сессии, начиная с первого
+	IPHistory []*models.SessionIP `json:"ip_history"`
 }
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"auth-service/internal/models"
+	"auth-service/pkg/er"
+)
+
+const (
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeAuthorizationCode = "authorization_code"
+
+	ResponseTypeCode        = "code"
+	CodeChallengeMethodS256 = "S256"
+	authorizationCodeSize   = 32
+	minCodeVerifierLength   = 43
+	maxCodeVerifierLength   = 128
+
+	// ScopeIntrospect разрешает клиенту вызывать introspection endpoint
+	ScopeIntrospect = "introspect"
+)
+
+// dummySecretHash используется для неизвестных client_id, чтобы время проверки не выдавало существующих клиентов
+var dummySecretHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-client-secret"), bcrypt.DefaultCost)
+
+// AuthenticateClient проверяет client_id и секрет OAuth2 клиента
+func (s *Service) AuthenticateClient(ctx context.Context, clientID, secret string) (*models.Client, error) {
+	client, err := s.repo.GetClientByID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, er.ErrNotFound) {
+			_ = bcrypt.CompareHashAndPassword(dummySecretHash, []byte(secret))
+			return nil, er.ErrInvalidClient
+		}
+		return nil, fmt.Errorf("failed to get client: %w", err)
+	}
+	if client.IsPublic() {
+		return nil, er.ErrInvalidClient
+	}
+	if bcrypt.CompareHashAndPassword([]byte(client.SecretHash), []byte(secret)) != nil {
+		return nil, er.ErrInvalidClient
+	}
+	return client, nil
+}
+
+// ClientCredentials выдаёт access токен клиенту от его собственного имени (RFC 6749, 4.4).
+// Refresh токен не выдаётся: клиент может в любой момент получить новый токен по своим учётным данным.
+// Если клиент предъявил TLS сертификат (certThumbprint), токен привязывается к нему (RFC 8705).
+func (s *Service) ClientCredentials(ctx context.Context, clientID, secret, scope, certThumbprint string) (*IssuedToken, error) {
+	client, err := s.AuthenticateClient(ctx, clientID, secret)
+	if err != nil {
+		return nil, err
+	}
+	granted, err := grantScope(client.Scopes, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := s.newAccessTokenClaims(time.Now())
+	claims.Subject = client.ClientID
+	claims.ClientID = client.ClientID
+	claims.Scope = granted
+	if certThumbprint != "" {
+		claims.Confirmation = &models.Confirmation{X5TS256: certThumbprint}
+	}
+	accessToken, err := s.generateAccessToken(claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	return &IssuedToken{
+		AccessToken: accessToken,
+		ExpiresIn:   int64(s.accessTTL.Seconds()),
+		Scope:       granted,
+	}, nil
+}
+
+// Authorize обрабатывает запрос авторизации и возвращает URL, на который нужно перенаправить пользователя:
+// с кодом при успехе или с параметром error по RFC 6749, 4.1.2.1.
+// Если клиент неизвестен или redirect_uri не зарегистрирован, перенаправлять некуда —
+// возвращаются er.ErrInvalidClient и er.ErrInvalidRedirect.
+func (s *Service) Authorize(ctx context.Context, req AuthorizeRequest) (string, error) {
+	client, err := s.repo.GetClientByID(ctx, req.ClientID)
+	if err != nil {
+		if errors.Is(err, er.ErrNotFound) {
+			return "", er.ErrInvalidClient
+		}
+		return "", fmt.Errorf("failed to get client: %w", err)
+	}
+	redirectURI, err := resolveRedirectURI(client, req.RedirectURI)
+	if err != nil {
+		return "", err
+	}
+
+	redirectError := func(code, description string) (string, error) {
+		params := url.Values{"error": {code}}
+		if description != "" {
+			params.Set("error_description", description)
+		}
+		return buildRedirect(redirectURI, params, req.State), nil
+	}
+
+	if req.ResponseType != ResponseTypeCode {
+		return redirectError("unsupported_response_type", "")
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != CodeChallengeMethodS256 {
+		return redirectError("invalid_request", "PKCE with code_challenge_method=S256 is required")
+	}
+	scope, err := grantScope(client.Scopes, req.Scope)
+	if err != nil {
+		return redirectError("invalid_scope", err.Error())
+	}
+	if err := s.checkUser(ctx, req.UserID); err != nil {
+		if errors.Is(err, er.ErrNotFound) {
+			return redirectError("access_denied", "user not found")
+		}
+		if errors.Is(err, er.ErrUserDisabled) {
+			return redirectError("access_denied", "user is disabled")
+		}
+		return "", err
+	}
+
+	code, err := generateRandomBase64(authorizationCodeSize)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate authorization code: %w", err)
+	}
+	err = s.repo.CreateAuthorizationCode(ctx, &models.AuthorizationCode{
+		CodeHash:      hashAuthorizationCode(code),
+		ClientID:      client.ClientID,
+		UserID:        req.UserID,
+		RedirectURI:   redirectURI,
+		CodeChallenge: req.CodeChallenge,
+		Scope:         scope,
+		Nonce:         req.Nonce,
+		ExpiresAt:     time.Now().Add(s.authCodeTTL),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to save authorization code: %w", err)
+	}
+	return buildRedirect(redirectURI, url.Values{"code": {code}}, req.State), nil
+}
+
+// ExchangeAuthorizationCode обменивает одноразовый код на пару токенов после проверки PKCE verifier.
+// Сессия создаётся так же, как в GenerateTokens; id_token выдаётся, если запрошен scope openid.
+func (s *Service) ExchangeAuthorizationCode(ctx context.Context, req CodeExchangeRequest) (*IssuedToken, error) {
+	client, err := s.repo.GetClientByID(ctx, req.ClientID)
+	if err != nil {
+		if errors.Is(err, er.ErrNotFound) {
+			return nil, er.ErrInvalidClient
+		}
+		return nil, fmt.Errorf("failed to get client: %w", err)
+	}
+	if !client.IsPublic() {
+		if _, err := s.AuthenticateClient(ctx, req.ClientID, req.ClientSecret); err != nil {
+			return nil, err
+		}
+	}
+	if len(req.CodeVerifier) < minCodeVerifierLength || len(req.CodeVerifier) > maxCodeVerifierLength {
+		return nil, fmt.Errorf("%w: invalid code_verifier", er.ErrInvalidGrant)
+	}
+
+	code, err := s.repo.ConsumeAuthorizationCode(ctx, hashAuthorizationCode(req.Code))
+	if err != nil {
+		if errors.Is(err, er.ErrNotFound) {
+			return nil, er.ErrInvalidGrant
+		}
+		return nil, fmt.Errorf("failed to consume authorization code: %w", err)
+	}
+	if code.ClientID != client.ClientID || code.RedirectURI != req.RedirectURI {
+		return nil, er.ErrInvalidGrant
+	}
+	if !verifyCodeChallenge(req.CodeVerifier, code.CodeChallenge) {
+		return nil, fmt.Errorf("%w: code_verifier mismatch", er.ErrInvalidGrant)
+	}
+
+	pair, err := s.generateTokens(ctx, issueParams{
+		userID:    code.UserID,
+		userAgent: req.UserAgent,
+		ip:        req.IP,
+		clientID:  client.ClientID,
+		scope:     code.Scope,
+		nonce:     code.Nonce,
+	})
+	if err != nil {
+		if errors.Is(err, er.ErrNotFound) {
+			return nil, er.ErrInvalidGrant
+		}
+		return nil, err
+	}
+	issued := &IssuedToken{
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    int64(s.accessTTL.Seconds()),
+		Scope:        pair.Scope,
+	}
+	if containsScope(strings.Fields(code.Scope), ScopeOpenID) {
+		issued.IDToken = pair.IDToken
+	}
+	return issued, nil
+}
+
+// resolveRedirectURI сверяет redirect_uri с зарегистрированными у клиента (точное совпадение).
+// Если параметр не передан, используется единственный зарегистрированный URI.
+func resolveRedirectURI(client *models.Client, redirectURI string) (string, error) {
+	if redirectURI == "" {
+		if len(client.RedirectURIs) == 1 {
+			return client.RedirectURIs[0], nil
+		}
+		return "", er.ErrInvalidRedirect
+	}
+	for _, registered := range client.RedirectURIs {
+		if registered == redirectURI {
+			return redirectURI, nil
+		}
+	}
+	return "", er.ErrInvalidRedirect
+}
+
+// verifyCodeChallenge сверяет PKCE verifier с challenge метода S256 (RFC 7636, 4.6)
+func verifyCodeChallenge(verifier, challenge string) bool {
+	sum := sha256.Sum256([]byte(verifier))
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(challenge)) == 1
+}
+
+func buildRedirect(redirectURI string, params url.Values, state string) string {
+	if state != "" {
+		params.Set("state", state)
+	}
+	separator := "?"
+	if strings.Contains(redirectURI, "?") {
+		separator = "&"
+	}
+	return redirectURI + separator + params.Encode()
+}
+
+func hashAuthorizationCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// grantScope проверяет, что запрошенные scope разрешены; пустой запрос получает все разрешённые
+func grantScope(allowed []string, requested string) (string, error) {
+	if strings.TrimSpace(requested) == "" {
+		return strings.Join(allowed, " "), nil
+	}
+	scopes := strings.Fields(requested)
+	for _, scope := range scopes {
+		if !containsScope(allowed, scope) {
+			return "", fmt.Errorf("%w: %s", er.ErrInvalidScope, scope)
+		}
+	}
+	return strings.Join(scopes, " "), nil
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
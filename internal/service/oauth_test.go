@@ -0,0 +1,120 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"auth-service/internal/models"
+	"auth-service/pkg/er"
+)
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	// пример из RFC 7636, приложение B
+	const (
+		verifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+		challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	)
+	if !verifyCodeChallenge(verifier, challenge) {
+		t.Fatal("RFC 7636 verifier must match its challenge")
+	}
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+	}{
+		{"other verifier", verifier + "x", challenge},
+		{"plain method", verifier, verifier},
+		{"padded challenge", verifier, challenge + "="},
+		{"empty challenge", verifier, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if verifyCodeChallenge(tt.verifier, tt.challenge) {
+				t.Fatal("verifyCodeChallenge() = true, want false")
+			}
+		})
+	}
+}
+
+func TestResolveRedirectURI(t *testing.T) {
+	single := &models.Client{RedirectURIs: []string{"https://app.example.com/cb"}}
+	several := &models.Client{RedirectURIs: []string{"https://app.example.com/cb", "myapp://cb"}}
+
+	tests := []struct {
+		name   string
+		client *models.Client
+		uri    string
+		want   string
+	}{
+		{"default single", single, "", "https://app.example.com/cb"},
+		{"exact", several, "myapp://cb", "myapp://cb"},
+		{"no default with several", several, "", ""},
+		{"prefix", single, "https://app.example.com/cb/evil", ""},
+		{"query", single, "https://app.example.com/cb?x=1", ""},
+		{"case", single, "https://APP.example.com/cb", ""},
+		{"none registered", &models.Client{}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveRedirectURI(tt.client, tt.uri)
+			if tt.want == "" {
+				if !errors.Is(err, er.ErrInvalidRedirect) {
+					t.Fatalf("resolveRedirectURI() = %q, %v; want %v", got, err, er.ErrInvalidRedirect)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("resolveRedirectURI() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrantScope(t *testing.T) {
+	allowed := []string{"openid", "sessions:read", "profile"}
+
+	tests := []struct {
+		name      string
+		requested string
+		want      string
+		wantErr   bool
+	}{
+		{"empty gets all allowed", "", "openid sessions:read profile", false},
+		{"blank gets all allowed", "  ", "openid sessions:read profile", false},
+		{"subset keeps order", "profile openid", "profile openid", false},
+		{"extra spaces", " openid   profile ", "openid profile", false},
+		{"not allowed", "openid admin", "", true},
+		{"prefix of allowed", "session", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := grantScope(allowed, tt.requested)
+			if tt.wantErr {
+				if !errors.Is(err, er.ErrInvalidScope) {
+					t.Fatalf("grantScope() = %q, %v; want %v", got, err, er.ErrInvalidScope)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("grantScope() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildRedirect(t *testing.T) {
+	tests := []struct {
+		uri   string
+		state string
+		want  string
+	}{
+		{"https://app.example.com/cb", "", "https://app.example.com/cb?code=abc"},
+		{"https://app.example.com/cb", "s 1", "https://app.example.com/cb?code=abc&state=s+1"},
+		{"https://app.example.com/cb?tenant=a", "s", "https://app.example.com/cb?tenant=a&code=abc&state=s"},
+	}
+	for _, tt := range tests {
+		if got := buildRedirect(tt.uri, map[string][]string{"code": {"abc"}}, tt.state); got != tt.want {
+			t.Errorf("buildRedirect(%q, %q) = %q, want %q", tt.uri, tt.state, got, tt.want)
+		}
+	}
+}
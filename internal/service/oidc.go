@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"auth-service/internal/models"
+	"auth-service/pkg/dpop"
+	"auth-service/pkg/er"
+)
+
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+)
+
+// OpenIDConfiguration возвращает документ /.well-known/openid-configuration
+func (s *Service) OpenIDConfiguration() OpenIDConfiguration {
+	return OpenIDConfiguration{
+		Issuer:                                s.issuer,
+		AuthorizationEndpoint:                 s.issuer + "/api/oauth/authorize",
+		TokenEndpoint:                         s.issuer + "/api/oauth/token",
+		UserinfoEndpoint:                      s.issuer + "/api/userinfo",
+		JwksURI:                               s.issuer + "/.well-known/jwks.json",
+		IntrospectionEndpoint:                 s.issuer + "/api/introspect",
+		RevocationEndpoint:                    s.issuer + "/api/revoke",
+		ResponseTypesSupported:                []string{ResponseTypeCode},
+		GrantTypesSupported:                   []string{GrantTypeAuthorizationCode, GrantTypeClientCredentials},
+		SubjectTypesSupported:                 []string{"public"},
+		IDTokenSigningAlgValuesSupported:      s.idTokenKeys.algorithms(),
+		ScopesSupported:                       []string{ScopeOpenID, ScopeProfile},
+		ClaimsSupported:                       []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "sid", "guid", "created_at", "updated_at"},
+		CodeChallengeMethodsSupported:         []string{CodeChallengeMethodS256},
+		DPoPSigningAlgValuesSupported:         dpop.SigningAlgs,
+		TLSClientCertificateBoundAccessTokens: true,
+		TokenEndpointAuthMethodsSupported:     []string{"client_secret_basic", "client_secret_post", "none"},
+	}
+}
+
+// UserInfo возвращает claims пользователя для /userinfo
+func (s *Service) UserInfo(ctx context.Context, userID uuid.UUID) (*UserInfo, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, er.ErrNotFound) {
+			return nil, er.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get user by id %s: %w", userID, err)
+	}
+	return &UserInfo{
+		Sub:       user.ID.String(),
+		GUID:      user.ID.String(),
+		CreatedAt: user.CreatedAt.Unix(),
+		UpdatedAt: user.UpdatedAt.Unix(),
+	}, nil
+}
+
+// generateIDToken выпускает id_token сессии. Audience — OAuth2 клиент сессии,
+// а при прямой выдаче через /tokens/{guid} — сам сервис (issuer).
+func (s *Service) generateIDToken(p issueParams, now time.Time) (string, error) {
+	audience := p.clientID
+	if audience == "" {
+		audience = s.issuer
+	}
+	claims := models.IDTokenClaims{
+		Nonce:     p.nonce,
+		SessionID: p.familyID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   p.userID.String(),
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTTL)),
+		},
+	}
+	if p.parentID == nil {
+		claims.AuthTime = now.Unix()
+	}
+	return signJWT(s.idTokenKeys, claims)
+}
@@ -0,0 +1,325 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"auth-service/internal/models"
+	"auth-service/pkg/argon2id"
+	"auth-service/pkg/er"
+)
+
+// maxLoginLength совпадает с длиной колонки credentials.login
+const maxLoginLength = 254
+
+// passwordPolicy параметры хеширования и требования к новым паролям
+type passwordPolicy struct {
+	params    argon2id.Params
+	minLength int
+	maxLength int
+	// breached SHA-1 паролей из BREACHED_PASSWORDS_FILE
+	breached map[[sha1.Size]byte]struct{}
+	// dummyHash проверяется для неизвестных логинов, чтобы время ответа не выдавало существующих пользователей
+	dummyHash string
+	// slots ограничивает число одновременных вычислений Argon2id, каждое из которых занимает params.Memory KiB
+	slots   chan struct{}
+	maxWait time.Duration
+}
+
+func newPasswordPolicy(cfg Config) (*passwordPolicy, error) {
+	params := argon2id.Params{
+		Memory:      cfg.Argon2Memory,
+		Iterations:  cfg.Argon2Iterations,
+		Parallelism: cfg.Argon2Parallelism,
+		SaltLength:  argon2id.DefaultSaltLength,
+		KeyLength:   argon2id.DefaultKeyLength,
+	}
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordMinLength < 1 || cfg.PasswordMaxLength < cfg.PasswordMinLength {
+		return nil, fmt.Errorf("invalid password length limits %d..%d", cfg.PasswordMinLength, cfg.PasswordMaxLength)
+	}
+	breached, err := loadBreachedPasswords(cfg.BreachedPasswordsFile)
+	if err != nil {
+		return nil, err
+	}
+	concurrency := cfg.PasswordHashConcurrency
+	if concurrency < 0 {
+		return nil, fmt.Errorf("invalid password hash concurrency %d", concurrency)
+	}
+	if concurrency == 0 {
+		concurrency = runtime.NumCPU()
+	}
+	if cfg.PasswordHashMaxWait < 0 {
+		return nil, fmt.Errorf("invalid password hash max wait %s", cfg.PasswordHashMaxWait)
+	}
+	dummyHash, err := argon2id.Hash("dummy-password", params)
+	if err != nil {
+		return nil, err
+	}
+	return &passwordPolicy{
+		params:    params,
+		minLength: cfg.PasswordMinLength,
+		maxLength: cfg.PasswordMaxLength,
+		breached:  breached,
+		dummyHash: dummyHash,
+		slots:     make(chan struct{}, concurrency),
+		maxWait:   cfg.PasswordHashMaxWait,
+	}, nil
+}
+
+// acquire занимает слот для вычисления Argon2id. Если слот не освободился за maxWait,
+// возвращается er.ErrServerBusy: запросы сверх лимита отклоняются, а не копятся в памяти.
+func (p *passwordPolicy) acquire(ctx context.Context) (func(), error) {
+	release := func() { <-p.slots }
+	select {
+	case p.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+	timer := time.NewTimer(p.maxWait)
+	defer timer.Stop()
+	select {
+	case p.slots <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, er.ErrServerBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// hash хеширует пароль текущими параметрами в пределах лимита одновременных вычислений
+func (p *passwordPolicy) hash(ctx context.Context, password string) (string, error) {
+	release, err := p.acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return argon2id.Hash(password, p.params)
+}
+
+// verify сравнивает пароль с хешем в пределах лимита одновременных вычислений
+func (p *passwordPolicy) verify(ctx context.Context, password, encoded string) (bool, error) {
+	release, err := p.acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+	return argon2id.Verify(password, encoded)
+}
+
+// loadBreachedPasswords читает список скомпрометированных паролей: по одному на строку,
+// либо открытым текстом, либо SHA-1 в hex в формате выгрузки Have I Been Pwned (<SHA-1>[:<count>])
+func loadBreachedPasswords(path string) (map[[sha1.Size]byte]struct{}, error) {
+	breached := make(map[[sha1.Size]byte]struct{})
+	if path == "" {
+		return breached, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached passwords file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if sum, ok := parseSHA1Line(line); ok {
+			breached[sum] = struct{}{}
+			continue
+		}
+		breached[sha1.Sum([]byte(line))] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached passwords file: %w", err)
+	}
+	zap.S().Infof("loaded %d breached passwords", len(breached))
+	return breached, nil
+}
+
+func parseSHA1Line(line string) ([sha1.Size]byte, bool) {
+	var sum [sha1.Size]byte
+	digest, _, _ := strings.Cut(line, ":")
+	if len(digest) != hex.EncodedLen(sha1.Size) {
+		return sum, false
+	}
+	if _, err := hex.Decode(sum[:], []byte(digest)); err != nil {
+		return sum, false
+	}
+	return sum, true
+}
+
+// check проверяет новый пароль: длину в символах, совпадение с логином и наличие в списке утёкших.
+// Ошибка оборачивает er.ErrWeakPassword и содержит причину.
+func (p *passwordPolicy) check(login, password string) error {
+	if !utf8.ValidString(password) {
+		return fmt.Errorf("%w: password must be valid UTF-8", er.ErrWeakPassword)
+	}
+	length := utf8.RuneCountInString(password)
+	if length < p.minLength {
+		return fmt.Errorf("%w: password must be at least %d characters long", er.ErrWeakPassword, p.minLength)
+	}
+	if length > p.maxLength {
+		return fmt.Errorf("%w: password must be at most %d characters long", er.ErrWeakPassword, p.maxLength)
+	}
+	if strings.EqualFold(password, login) {
+		return fmt.Errorf("%w: password must not match login", er.ErrWeakPassword)
+	}
+	if _, ok := p.breached[sha1.Sum([]byte(password))]; ok {
+		return fmt.Errorf("%w: password is known to be breached", er.ErrWeakPassword)
+	}
+	return nil
+}
+
+// normalizeLogin приводит логин к нижнему регистру и проверяет его
+func normalizeLogin(login string) (string, error) {
+	login = strings.ToLower(strings.TrimSpace(login))
+	if login == "" || utf8.RuneCountInString(login) > maxLoginLength {
+		return "", fmt.Errorf("%w: login must be 1 to %d characters long", er.ErrInvalidLogin, maxLoginLength)
+	}
+	if strings.IndexFunc(login, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return "", fmt.Errorf("%w: login must not contain spaces or control characters", er.ErrInvalidLogin)
+	}
+	return login, nil
+}
+
+// Register создаёт пользователя с ролью RoleUser и паролем, удовлетворяющим политике паролей.
+// Возвращает er.ErrAlreadyExists, если логин занят.
+func (s *Service) Register(ctx context.Context, login, password string) (*models.User, error) {
+	login, err := normalizeLogin(login)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.passwords.check(login, password); err != nil {
+		return nil, err
+	}
+	hash, err := s.passwords.hash(ctx, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+	user, err := s.repo.CreateUserWithCredentials(ctx, uuid.New(), RoleUser, &models.Credentials{
+		Login:        login,
+		PasswordHash: hash,
+	})
+	if err != nil {
+		if errors.Is(err, er.ErrAlreadyExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	return user, nil
+}
+
+// Login проверяет логин и пароль и выдаёт пару токенов так же, как GenerateTokens.
+// Неизвестный логин и неверный пароль неразличимы: в обоих случаях возвращается er.ErrInvalidPassword.
+// Хеш, вычисленный с устаревшими параметрами Argon2id, пересчитывается с текущими.
+func (s *Service) Login(ctx context.Context, login, password, userAgent, ip, dpopJKT string) (*TokenPair, error) {
+	login, err := normalizeLogin(login)
+	if err != nil {
+		return nil, er.ErrInvalidPassword
+	}
+	// пароль длиннее любого допустимого не хешируется, чтобы длинные запросы не нагружали сервер
+	if len(password) > s.passwords.maxLength*utf8.UTFMax {
+		return nil, er.ErrInvalidPassword
+	}
+	credentials, err := s.repo.GetCredentialsByLogin(ctx, login)
+	if err != nil {
+		if errors.Is(err, er.ErrNotFound) {
+			if _, err := s.passwords.verify(ctx, password, s.passwords.dummyHash); err != nil {
+				return nil, fmt.Errorf("failed to verify password: %w", err)
+			}
+			return nil, er.ErrInvalidPassword
+		}
+		return nil, fmt.Errorf("failed to get credentials: %w", err)
+	}
+	if err := s.verifyPassword(ctx, credentials, password); err != nil {
+		return nil, err
+	}
+	return s.GenerateTokens(ctx, credentials.UserID, userAgent, ip, dpopJKT)
+}
+
+// ChangePassword меняет пароль пользователя после проверки текущего и завершает все его сессии,
+// кроме currentSessionID. Возвращает число завершённых сессий.
+// Если у пользователя нет пароля, возвращается er.ErrNotFound.
+func (s *Service) ChangePassword(ctx context.Context, userID, currentSessionID uuid.UUID, currentPassword, newPassword string) (int, error) {
+	credentials, err := s.repo.GetCredentialsByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, er.ErrNotFound) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("failed to get credentials: %w", err)
+	}
+	if len(currentPassword) > s.passwords.maxLength*utf8.UTFMax {
+		return 0, er.ErrInvalidPassword
+	}
+	if err := s.verifyPassword(ctx, credentials, currentPassword); err != nil {
+		return 0, err
+	}
+	if err := s.passwords.check(credentials.Login, newPassword); err != nil {
+		return 0, err
+	}
+	hash, err := s.passwords.hash(ctx, newPassword)
+	if err != nil {
+		return 0, fmt.Errorf("failed to hash password: %w", err)
+	}
+	// verifyPassword мог пересчитать хеш текущего пароля, поэтому сравнение идёт с актуальным значением
+	if err := s.repo.UpdatePasswordHash(ctx, userID, credentials.PasswordHash, hash); err != nil {
+		if errors.Is(err, er.ErrNotFound) {
+			// пароль успели сменить параллельным запросом
+			return 0, er.ErrInvalidPassword
+		}
+		return 0, fmt.Errorf("failed to update password: %w", err)
+	}
+	revoked, err := s.RevokeOtherSessions(ctx, userID, currentSessionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions after password change: %w", err)
+	}
+	return revoked, nil
+}
+
+// verifyPassword сравнивает пароль с хешем и при необходимости пересчитывает хеш с текущими параметрами.
+// Ошибка пересчёта не мешает входу: старый хеш остаётся рабочим и будет обновлён при следующем входе.
+func (s *Service) verifyPassword(ctx context.Context, credentials *models.Credentials, password string) error {
+	ok, err := s.passwords.verify(ctx, password, credentials.PasswordHash)
+	if err != nil {
+		return fmt.Errorf("failed to verify password of user %s: %w", credentials.UserID, err)
+	}
+	if !ok {
+		return er.ErrInvalidPassword
+	}
+	if !argon2id.NeedsRehash(credentials.PasswordHash, s.passwords.params) {
+		return nil
+	}
+	hash, err := s.passwords.hash(ctx, password)
+	if err != nil {
+		zap.S().Warnf("failed to rehash password of user %s: %s", credentials.UserID, err)
+		return nil
+	}
+	if err := s.repo.UpdatePasswordHash(ctx, credentials.UserID, credentials.PasswordHash, hash); err != nil {
+		if !errors.Is(err, er.ErrNotFound) {
+			zap.S().Warnf("failed to save rehashed password of user %s: %s", credentials.UserID, err)
+		}
+		return nil
+	}
+	zap.S().Infof("password hash of user %s upgraded to current argon2id parameters", credentials.UserID)
+	credentials.PasswordHash = hash
+	return nil
+}
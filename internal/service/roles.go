@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// userGrants возвращает роли пользователя и scope для его access токена.
+// Для сессий OAuth2 клиента (restrict) scope ограничен запрошенным клиентом, иначе токен получает все scope ролей.
+func (s *Service) userGrants(ctx context.Context, userID uuid.UUID, requested string, restrict bool) ([]string, string, error) {
+	roles, err := s.repo.GetUserRoles(ctx, userID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get user roles: %w", err)
+	}
+
+	var names, scopes []string
+	for _, role := range roles {
+		names = append(names, role.Name)
+		for _, scope := range role.Scopes {
+			if !containsScope(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	if !restrict {
+		return names, strings.Join(scopes, " "), nil
+	}
+
+	var granted []string
+	for _, scope := range strings.Fields(requested) {
+		if containsScope(scopes, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	return names, strings.Join(granted, " "), nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"crypto/rand"
@@ -17,156 +18,300 @@ import (
 
 	"auth-service/internal/models"
 	"auth-service/internal/repository"
+	"auth-service/pkg/dpop"
 	"auth-service/pkg/er"
+	"auth-service/pkg/jwk"
 )
 
 type Config struct {
-	JwtSecret  string        `env:"JWT_SECRET,required"`
-	AccessTTL  time.Duration `env:"ACCESS_TTL,required"`
-	RefreshTTL time.Duration `env:"REFRESH_TTL,required"`
-	WebhookURL string        `env:"WEBHOOK_URL,required"`
-	UserAgent  string        `env:"USER_AGENT"`
+	JwtSecret                string        `env:"JWT_SECRET"`
+	JwtSigningMethod         string        `env:"JWT_SIGNING_METHOD" envDefault:"HS512"`
+	JwtPrivateKeyFile        string        `env:"JWT_PRIVATE_KEY_FILE"`
+	JwtKeyringFile           string        `env:"JWT_KEYRING_FILE"`
+	IDTokenSigningMethod     string        `env:"ID_TOKEN_SIGNING_METHOD" envDefault:"RS256"`
+	IDTokenPrivateKeyFile    string        `env:"ID_TOKEN_PRIVATE_KEY_FILE"`
+	AccessTTL                time.Duration `env:"ACCESS_TTL,required"`
+	RefreshTTL               time.Duration `env:"REFRESH_TTL,required"`
+	WebhookURL               string        `env:"WEBHOOK_URL,required"`
+	UserAgent                string        `env:"USER_AGENT"`
+	AuthCodeTTL              time.Duration `env:"AUTH_CODE_TTL" envDefault:"1m"`
+	IssuerURL                string        `env:"ISSUER_URL" envDefault:"http://localhost:8081"`
+	JwtAudience              string        `env:"JWT_AUDIENCE" envDefault:"auth-service"`
+	JwtLeeway                time.Duration `env:"JWT_LEEWAY" envDefault:"30s"`
+	AccessTokenFormat        string        `env:"ACCESS_TOKEN_FORMAT" envDefault:"jwt"`
+	PasetoPrivateKeyFile     string        `env:"PASETO_PRIVATE_KEY_FILE"`
+	DPoPProofMaxAge          time.Duration `env:"DPOP_PROOF_MAX_AGE" envDefault:"60s"`
+	MaxSessionsPerUser       int           `env:"MAX_SESSIONS_PER_USER" envDefault:"0"`
+	SessionLimitPolicy       string        `env:"SESSION_LIMIT_POLICY" envDefault:"evict_oldest"`
+	UserAgentMatchPolicy     string        `env:"UA_MATCH_POLICY" envDefault:"family"`
+	IssuanceDevMode          bool          `env:"ISSUANCE_DEV_MODE" envDefault:"false"`
+	IssuanceAssertionKeyFile string        `env:"ISSUANCE_ASSERTION_KEY_FILE"`
+	IssuanceAssertionIssuer  string        `env:"ISSUANCE_ASSERTION_ISSUER"`
+	Argon2Memory             uint32        `env:"ARGON2_MEMORY" envDefault:"65536"`
+	Argon2Iterations         uint32        `env:"ARGON2_ITERATIONS" envDefault:"3"`
+	Argon2Parallelism        uint8         `env:"ARGON2_PARALLELISM" envDefault:"4"`
+	PasswordMinLength        int           `env:"PASSWORD_MIN_LENGTH" envDefault:"12"`
+	PasswordMaxLength        int           `env:"PASSWORD_MAX_LENGTH" envDefault:"128"`
+	BreachedPasswordsFile    string        `env:"BREACHED_PASSWORDS_FILE"`
+	PasswordHashConcurrency  int           `env:"PASSWORD_HASH_CONCURRENCY" envDefault:"0"`
+	PasswordHashMaxWait      time.Duration `env:"PASSWORD_HASH_MAX_WAIT" envDefault:"2s"`
+
+	// AccessExpiredGrace если не задан, равен AccessTTL
+	AccessExpiredGrace *time.Duration `env:"ACCESS_EXPIRED_GRACE"`
 }
 
+const (
+	// refresh токен имеет вид <selector>.<verifier>: selector ищется по уникальному индексу,
+	// verifier хранится только в виде bcrypt хеша
+	refreshTokenSeparator = "."
+	refreshSelectorSize   = 16
+	refreshVerifierSize   = 32
+)
+
 type Service struct {
-	repo       repository.Repository
-	jwtSecret  []byte
-	accessTTL  time.Duration
-	refreshTTL time.Duration
-	client     *resty.Client
+	repo        repository.Repository
+	keys        *keyring
+	idTokenKeys *keyring
+	accessTTL   time.Duration
+	refreshTTL  time.Duration
+	authCodeTTL time.Duration
+	issuer      string
+	audience    string
+	leeway      time.Duration
+	accessGrace time.Duration
+	codec       accessTokenCodec
+	dpopMaxAge  time.Duration
+	dpopReplay  *dpop.ReplayCache
+	// sessionLimit.Max == 0 отключает ограничение числа сессий
+	sessionLimit  models.SessionLimit
+	uaMatchPolicy string
+	// issuanceDevMode разрешает выдачу токенов без аутентификации вызывающего
+	issuanceDevMode bool
+	// issuanceAssertions nil, если подписанные утверждения identity front-end не настроены
+	issuanceAssertions *issuanceAssertionVerifier
+	passwords          *passwordPolicy
+	client             *resty.Client
 }
 
-func NewService(repo repository.Repository, cfg Config) *Service {
+func NewService(repo repository.Repository, cfg Config) (*Service, error) {
+	if cfg.IssuerURL == "" || cfg.JwtAudience == "" {
+		return nil, errors.New("issuer and audience of access tokens must not be empty")
+	}
+	keys, err := newKeyring(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signing keys: %w", err)
+	}
+	// по умолчанию истёкший access токен принимается для обновления столько же, сколько он действовал
+	accessGrace := cfg.AccessTTL
+	if cfg.AccessExpiredGrace != nil {
+		accessGrace = *cfg.AccessExpiredGrace
+	}
+	if accessGrace < 0 {
+		return nil, fmt.Errorf("invalid access token expiry grace %s", accessGrace)
+	}
+	idTokenKeys, err := newIDTokenKeyring(cfg, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load id_token signing key: %w", err)
+	}
+	codec, err := newAccessTokenCodec(cfg, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up access token format: %w", err)
+	}
+	sessionLimit, err := newSessionLimit(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateUserAgentMatchPolicy(cfg.UserAgentMatchPolicy); err != nil {
+		return nil, err
+	}
+	issuanceAssertions, err := newIssuanceAssertionVerifier(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up issuance assertions: %w", err)
+	}
+	passwords, err := newPasswordPolicy(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up password policy: %w", err)
+	}
+
 	client := resty.New()
 	client.SetBaseURL(cfg.WebhookURL)
 	if cfg.UserAgent != "" {
 		client.SetHeader("User-Agent", cfg.UserAgent)
 	}
 	s := &Service{
-		repo:       repo,
-		jwtSecret:  []byte(cfg.JwtSecret),
-		accessTTL:  cfg.AccessTTL,
-		refreshTTL: cfg.RefreshTTL,
-		client:     client,
+		repo:               repo,
+		keys:               keys,
+		idTokenKeys:        idTokenKeys,
+		accessTTL:          cfg.AccessTTL,
+		refreshTTL:         cfg.RefreshTTL,
+		authCodeTTL:        cfg.AuthCodeTTL,
+		issuer:             strings.TrimSuffix(cfg.IssuerURL, "/"),
+		audience:           cfg.JwtAudience,
+		leeway:             cfg.JwtLeeway,
+		accessGrace:        accessGrace,
+		codec:              codec,
+		dpopMaxAge:         cfg.DPoPProofMaxAge,
+		dpopReplay:         dpop.NewReplayCache(),
+		sessionLimit:       sessionLimit,
+		uaMatchPolicy:      cfg.UserAgentMatchPolicy,
+		issuanceDevMode:    cfg.IssuanceDevMode,
+		issuanceAssertions: issuanceAssertions,
+		passwords:          passwords,
+		client:             client,
 	}
-	return s
+	return s, nil
 }
 
-// GenerateTokens генерирует пару access и refresh токенов для пользователя
-func (s *Service) GenerateTokens(ctx context.Context, userID uuid.UUID, userAgent, ip string) (string, string, error) {
-	_, err := s.repo.GetUserByID(ctx, userID)
-	if err != nil {
-		if errors.Is(err, er.ErrNotFound) {
-			return "", "", er.ErrNotFound
-		}
-		return "", "", fmt.Errorf("failed to get user by id %s: %w", userID, err)
+// GenerateTokens генерирует пару access и refresh токенов и id_token для пользователя.
+// Если передан отпечаток DPoP ключа (VerifyDPoPProof), сессия привязывается к этому ключу.
+func (s *Service) GenerateTokens(ctx context.Context, userID uuid.UUID, userAgent, ip, dpopJKT string) (*TokenPair, error) {
+	return s.generateTokens(ctx, issueParams{userID: userID, userAgent: userAgent, ip: ip, dpopJKT: dpopJKT})
+}
+
+// generateTokens начинает новую сессию; используется прямой выдачей и OAuth2 authorization code
+func (s *Service) generateTokens(ctx context.Context, p issueParams) (*TokenPair, error) {
+	if err := s.checkUser(ctx, p.userID); err != nil {
+		return nil, err
 	}
+	p.familyID = uuid.New()
+	p.parentID = nil
+	return s.issueTokens(ctx, p)
+}
 
-	expiresAt := time.Now().Add(s.accessTTL)
-	accessToken, err := s.generateAccessToken(userID, expiresAt)
+// RefreshTokens обновляет пару токенов. Access и refresh токены должны быть выпущены вместе.
+// Истёкший access токен принимается в пределах ACCESS_EXPIRED_GRACE; если истёк срок access токена
+// сверх этого окна или срок refresh токена, возвращается er.ErrTokenExpired.
+// Для сессии, привязанной к DPoP ключу, dpopJKT должен совпадать с отпечатком этого ключа.
+// User-Agent сравнивается с User-Agent сессии по UA_MATCH_POLICY; при несовпадении завершаются все сессии пользователя.
+func (s *Service) RefreshTokens(ctx context.Context, accessToken, refreshTokenRaw, userAgent, ip, dpopJKT string) (*TokenPair, error) {
+	claims, err := s.parseExpiredAccessToken(accessToken)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to generate access token: %w", err)
+		return nil, err
 	}
+	userID, jti := claims.UserID, claims.JTI()
 
-	refreshTokenRaw, err := generateRandomBase64(32)
+	refreshToken, err := s.findRefreshToken(ctx, refreshTokenRaw)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to generate random refresh token: %w", err)
+		return nil, err
 	}
-	refreshTokenHash, err := bcrypt.GenerateFromPassword([]byte(refreshTokenRaw), bcrypt.DefaultCost)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to hash refresh token: %w", err)
+	if refreshToken.UserID != userID || refreshToken.AccessJTI != jti {
+		return nil, er.ErrInvalidToken
 	}
-
-	rt := &models.RefreshToken{
-		UserID:    userID,
-		TokenHash: string(refreshTokenHash),
-		UserAgent: userAgent,
-		IP:        ip,
-		IssuedAt:  time.Now(),
-		ExpiresAt: time.Now().Add(s.refreshTTL),
-		IsValid:   true,
+	if !refreshToken.IsValid {
+		if refreshToken.RotatedAt != nil {
+			s.revokeReusedFamily(ctx, refreshToken, ip)
+			return nil, er.ErrTokenReuse
+		}
+		return nil, er.ErrInvalidToken
 	}
-	if err := s.repo.CreateRefreshToken(ctx, rt); err != nil {
-		return "", "", fmt.Errorf("failed to create refresh token: %w", err)
+	// access токен ротированной пары отозван, поэтому список отозванных проверяется
+	// только после проверки на повторное использование refresh токена
+	if err := s.checkNotRevoked(ctx, claims); err != nil {
+		return nil, err
 	}
-
-	return accessToken, refreshTokenRaw, nil
-}
-
-// RefreshTokens обновляет пару токенов
-func (s *Service) RefreshTokens(ctx context.Context, userID uuid.UUID, refreshTokenRaw, userAgent, ip string) (string, string, error) {
-	refreshTokens, err := s.repo.GetValidUserRefreshTokens(ctx, userID)
-	if err != nil {
-		if errors.Is(err, er.ErrNotFound) {
-			return "", "", er.ErrNotFound
-		}
-		return "", "", fmt.Errorf("failed to get valid refresh tokens for user %s: %w", userID, err)
+	if refreshToken.ExpiresAt.Before(time.Now()) {
+		return nil, er.ErrTokenExpired
 	}
-	var refreshToken *models.RefreshToken
-	for _, t := range refreshTokens {
-		if bcrypt.CompareHashAndPassword([]byte(t.TokenHash), []byte(refreshTokenRaw)) == nil {
-			refreshToken = t
-			break
-		}
+	if refreshToken.DPoPJKT != "" && refreshToken.DPoPJKT != dpopJKT {
+		return nil, er.ErrInvalidDPoPProof
 	}
-	if refreshToken == nil {
-		return "", "", er.ErrInvalidToken
+	if !s.userAgentMatches(refreshToken, userAgent) {
+		if err := s.revokeAllUserSessions(ctx, refreshToken.UserID); err != nil {
+			zap.S().Errorf("failed to deauthorize user %s: %s", refreshToken.UserID, err)
+		}
+		return nil, er.ErrUserAgentMismatch
 	}
-	if refreshToken.UserAgent != userAgent {
-		_ = s.repo.InvalidateAllUserTokens(ctx, refreshToken.UserID)
-		return "", "", er.ErrUserAgentMismatch
+	if err := s.checkUser(ctx, refreshToken.UserID); err != nil {
+		return nil, err
 	}
 	if refreshToken.IP != ip {
 		err := s.Webhook(ctx, userID, ip)
 		if err != nil {
 			zap.S().Errorf("cannot send webhook: %s", err)
-			return "", "", fmt.Errorf("failed to send webhook: %w", err)
+			return nil, fmt.Errorf("failed to send webhook: %w", err)
 		}
 	}
 
-	_ = s.repo.InvalidateRefreshToken(ctx, refreshToken.TokenHash)
-
-	accessToken, refreshTokenRaw, err := s.GenerateTokens(ctx, refreshToken.UserID, userAgent, ip)
+	pair, err := s.issueTokens(ctx, issueParams{
+		userID:    refreshToken.UserID,
+		userAgent: userAgent,
+		ip:        ip,
+		familyID:  refreshToken.FamilyID,
+		parentID:  &refreshToken.ID,
+		clientID:  refreshToken.ClientID,
+		scope:     refreshToken.Scope,
+		dpopJKT:   refreshToken.DPoPJKT,
+		refreshes: refreshToken.RefreshCount + 1,
+	})
 	if err != nil {
-		return "", "", fmt.Errorf("failed to generate new tokens: %w", err)
+		if errors.Is(err, er.ErrInvalidToken) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to generate new tokens: %w", err)
 	}
-	return accessToken, refreshTokenRaw, nil
+	// access токен ротированной пары больше не нужен клиенту: новая пара уже выдана
+	if err := s.revokePairedAccessTokens(ctx, []*models.RefreshToken{refreshToken}); err != nil {
+		zap.S().Errorf("failed to revoke access token of rotated refresh token %d: %s", refreshToken.ID, err)
+	}
+	return pair, nil
 }
 
-// GetCurrentUserID возвращает userID по access токену
-func (s *Service) GetCurrentUserID(accessToken string) (uuid.UUID, error) {
-	claims, err := s.parseAccessToken(accessToken)
+// GetCurrentUserID возвращает userID по access токену, если токен не отозван.
+// Токены клиентов (client credentials) пользователя не содержат и считаются недействительными.
+func (s *Service) GetCurrentUserID(ctx context.Context, accessToken string) (uuid.UUID, error) {
+	claims, err := s.validateAccessToken(ctx, accessToken)
 	if err != nil {
-		if errors.Is(err, er.ErrInvalidToken) {
-			return uuid.Nil, er.ErrInvalidToken
-		}
-		return uuid.Nil, fmt.Errorf("failed to parse access token: %w", err)
+		return uuid.Nil, err
+	}
+	if claims.UserID == uuid.Nil {
+		return uuid.Nil, er.ErrInvalidToken
 	}
 	return claims.UserID, nil
 }
 
-// Logout деавторизует пользователя (инвалидирует все refresh токены)
+// ValidateAccessToken проверяет access токен пользователя или клиента и возвращает его claims
+func (s *Service) ValidateAccessToken(ctx context.Context, accessToken string) (*models.AccessTokenClaims, error) {
+	return s.validateAccessToken(ctx, accessToken)
+}
+
+// JWKS возвращает публичные ключи для проверки access токенов и id_token
+func (s *Service) JWKS() jwk.Set {
+	now := time.Now()
+	set := s.keys.jwks(now)
+	if s.idTokenKeys != s.keys {
+		set.Keys = append(set.Keys, s.idTokenKeys.jwks(now).Keys...)
+	}
+	return set
+}
+
+// Logout деавторизует пользователя: отзывает текущий access токен, access токены
+// всех его сессий и инвалидирует все refresh токены
 func (s *Service) Logout(ctx context.Context, accessToken string) error {
-	claims, err := s.parseAccessToken(accessToken)
+	claims, err := s.validateAccessToken(ctx, accessToken)
 	if err != nil {
-		if errors.Is(err, er.ErrInvalidToken) {
-			return er.ErrInvalidToken
+		return err
+	}
+	if jti := claims.JTI(); jti != uuid.Nil && claims.ExpiresAt != nil {
+		if err := s.repo.RevokeAccessToken(ctx, jti, s.revokedUntil(claims.Expiry())); err != nil {
+			return fmt.Errorf("failed to revoke access token: %w", err)
 		}
-		return fmt.Errorf("failed to parse access token: %w", err)
 	}
-	if err := s.repo.InvalidateAllUserTokens(ctx, claims.UserID); err != nil {
+	if err := s.revokeAllUserSessions(ctx, claims.UserID); err != nil {
 		return fmt.Errorf("failed to invalidate all user tokens: %w", err)
 	}
 	return nil
 }
 
 func (s *Service) Webhook(ctx context.Context, userID uuid.UUID, ip string) error {
-	req := WebhookRequest{
+	return s.sendWebhook(ctx, WebhookRequest{
+		Event:  EventIPChanged,
 		NewIP:  ip,
 		UserID: userID,
 		Ts:     time.Now().Unix(),
-	}
+	})
+}
 
+func (s *Service) sendWebhook(ctx context.Context, req WebhookRequest) error {
 	resp, err := s.client.R().SetBody(req).Post("")
 	if err != nil {
 		return fmt.Errorf("failed to send webhook: %w", err)
@@ -177,39 +322,337 @@ func (s *Service) Webhook(ctx context.Context, userID uuid.UUID, ip string) erro
 	return nil
 }
 
-func (s *Service) generateAccessToken(userID uuid.UUID, expiresAt time.Time) (string, error) {
-	claims := models.AccessTokenClaims{
-		UserID:    userID,
-		ExpiresAt: expiresAt.Unix(),
+// checkUser проверяет, что пользователь существует и не заблокирован
+func (s *Service) checkUser(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, er.ErrNotFound) {
+			return er.ErrNotFound
+		}
+		return fmt.Errorf("failed to get user by id %s: %w", userID, err)
+	}
+	if user.Disabled() {
+		return er.ErrUserDisabled
+	}
+	return nil
+}
+
+// issueParams параметры выпуска пары токенов
+type issueParams struct {
+	userID    uuid.UUID
+	userAgent string
+	ip        string
+	// familyID цепочка refresh токенов (sid); parentID — ротированный токен, если это обновление
+	familyID uuid.UUID
+	parentID *int
+	// clientID OAuth2 клиент, получивший сессию; становится aud id_token
+	clientID string
+	// scope запрошенный клиентом scope, ограничивает scope ролей пользователя в сессиях клиента
+	scope string
+	nonce string
+	// dpopJKT отпечаток DPoP ключа, к которому привязываются токены сессии
+	dpopJKT string
+	// refreshes сколько раз сессия обновлялась, включая текущее обновление
+	refreshes int
+}
+
+// issueTokens выпускает access токен, refresh токен и id_token в цепочке p.familyID
+func (s *Service) issueTokens(ctx context.Context, p issueParams) (*TokenPair, error) {
+	roles, scope, err := s.userGrants(ctx, p.userID, p.scope, p.clientID != "")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	claims := s.newAccessTokenClaims(now)
+	claims.Subject = p.userID.String()
+	claims.UserID = p.userID
+	claims.SessionID = p.familyID
+	claims.ClientID = p.clientID
+	claims.Scope = scope
+	claims.Roles = roles
+	tokenType := TokenTypeBearer
+	if p.dpopJKT != "" {
+		claims.Confirmation = &models.Confirmation{JKT: p.dpopJKT}
+		tokenType = TokenTypeDPoP
+	}
+	jti := claims.JTI()
+	accessToken, err := s.generateAccessToken(claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+	idToken, err := s.generateIDToken(p, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate id token: %w", err)
+	}
+
+	selector, err := generateRandomBase64(refreshSelectorSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token selector: %w", err)
+	}
+	verifier, err := generateRandomBase64(refreshVerifierSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate random refresh token: %w", err)
+	}
+	refreshTokenHash, err := bcrypt.GenerateFromPassword([]byte(verifier), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash refresh token: %w", err)
+	}
+
+	rt := &models.RefreshToken{
+		UserID:       p.userID,
+		Selector:     selector,
+		TokenHash:    string(refreshTokenHash),
+		UserAgent:    p.userAgent,
+		Device:       parseUserAgent(p.userAgent),
+		IP:           p.ip,
+		IssuedAt:     now,
+		ExpiresAt:    now.Add(s.refreshTTL),
+		IsValid:      true,
+		FamilyID:     p.familyID,
+		ParentID:     p.parentID,
+		AccessJTI:    jti,
+		ClientID:     p.clientID,
+		Scope:        p.scope,
+		DPoPJKT:      p.dpopJKT,
+		LastUsedAt:   now,
+		RefreshCount: p.refreshes,
+	}
+	if err := s.saveRefreshToken(ctx, rt); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: selector + refreshTokenSeparator + verifier,
+		IDToken:      idToken,
+		TokenType:    tokenType,
+		Scope:        scope,
+	}, nil
+}
+
+// revokeReusedFamily отзывает всю цепочку, в которой повторно предъявлен ротированный токен,
+// и сообщает о событии безопасности
+func (s *Service) revokeReusedFamily(ctx context.Context, refreshToken *models.RefreshToken, ip string) {
+	zap.S().Warnf("security event %s: user %s, family %s, ip %s, rotated token refresh count %d, issued at %s", EventRefreshTokenReuse,
+		refreshToken.UserID, refreshToken.FamilyID, ip, refreshToken.RefreshCount, refreshToken.IssuedAt.Format(time.RFC3339))
+	if err := s.revokeFamily(ctx, refreshToken.UserID, refreshToken.FamilyID); err != nil {
+		zap.S().Errorf("failed to revoke token family %s: %s", refreshToken.FamilyID, err)
+	}
+	err := s.sendWebhook(ctx, WebhookRequest{
+		Event:    EventRefreshTokenReuse,
+		NewIP:    ip,
+		UserID:   refreshToken.UserID,
+		FamilyID: &refreshToken.FamilyID,
+		Ts:       time.Now().Unix(),
+	})
+	if err != nil {
+		zap.S().Errorf("cannot send security event webhook: %s", err)
+	}
+}
+
+// revokeAllUserSessions инвалидирует все refresh токены пользователя и отзывает все его access токены,
+// которые ещё могут быть предъявлены, включая выпущенные с уже ротированными refresh токенами
+func (s *Service) revokeAllUserSessions(ctx context.Context, userID uuid.UUID) error {
+	tokens, err := s.liveUserTokens(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.InvalidateAllUserTokens(ctx, userID); err != nil {
+		return err
+	}
+	return s.revokePairedAccessTokens(ctx, tokens)
+}
+
+// revokeFamily инвалидирует цепочку refresh токенов и отзывает access токены всех её пар, которые ещё могут быть предъявлены
+func (s *Service) revokeFamily(ctx context.Context, userID, familyID uuid.UUID) error {
+	tokens, err := s.liveUserTokens(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.InvalidateTokenFamily(ctx, userID, familyID); err != nil {
+		return err
+	}
+	return s.revokePairedAccessTokens(ctx, familyTokens(tokens, familyID))
+}
+
+// liveUserTokens возвращает валидные refresh токены пользователя и токены, access токен пары которых
+// ещё не истёк с учётом leeway и ACCESS_EXPIRED_GRACE
+func (s *Service) liveUserTokens(ctx context.Context, userID uuid.UUID) ([]*models.RefreshToken, error) {
+	tokens, err := s.repo.GetLiveUserRefreshTokens(ctx, userID, s.liveTokensIssuedAfter())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get live refresh tokens for user %s: %w", userID, err)
+	}
+	return tokens, nil
+}
+
+// liveTokensIssuedAfter момент, после которого выпущенные access токены ещё могут быть предъявлены
+func (s *Service) liveTokensIssuedAfter() time.Time {
+	return time.Now().Add(-(s.accessTTL + s.leeway + s.accessGrace))
+}
+
+func familyTokens(tokens []*models.RefreshToken, familyID uuid.UUID) []*models.RefreshToken {
+	var family []*models.RefreshToken
+	for _, t := range tokens {
+		if t.FamilyID == familyID {
+			family = append(family, t)
+		}
+	}
+	return family
+}
+
+// revokePairedAccessTokens отзывает access токены, выпущенные вместе с переданными refresh токенами
+func (s *Service) revokePairedAccessTokens(ctx context.Context, tokens []*models.RefreshToken) error {
+	now := time.Now()
+	for _, t := range tokens {
+		expiresAt := s.revokedUntil(t.IssuedAt.Add(s.accessTTL))
+		if t.AccessJTI == uuid.Nil || expiresAt.Before(now) {
+			continue
+		}
+		if err := s.repo.RevokeAccessToken(ctx, t.AccessJTI, expiresAt); err != nil {
+			return fmt.Errorf("failed to revoke access token of session %s: %w", t.FamilyID, err)
+		}
+	}
+	return nil
+}
+
+// validateAccessToken проверяет подпись, срок действия и отсутствие токена в списке отозванных
+func (s *Service) validateAccessToken(ctx context.Context, accessToken string) (*models.AccessTokenClaims, error) {
+	claims, err := s.parseAccessToken(accessToken)
+	if err != nil {
+		zap.S().Infof("invalid access token: %s", err)
+		return nil, er.ErrInvalidToken
+	}
+	if err := s.checkNotRevoked(ctx, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// parseExpiredAccessToken проверяет access токен, предъявленный для обновления пары, кроме списка отозванных.
+// Все claims, кроме exp, проверяются как обычно; истёкший токен принимается ещё accessGrace после exp.
+func (s *Service) parseExpiredAccessToken(accessToken string) (*models.AccessTokenClaims, error) {
+	claims, err := s.codec.decode(accessToken)
+	if err != nil {
+		zap.S().Infof("invalid access token: %s", err)
+		return nil, er.ErrInvalidToken
+	}
+	expiry := claims.Expiry()
+	if expiry.IsZero() {
+		return nil, er.ErrInvalidToken
+	}
+	rest := *claims
+	rest.ExpiresAt = nil
+	if err := jwt.NewValidator(s.claimsOptions()...).Validate(&rest); err != nil {
+		zap.S().Infof("invalid access token: %s", err)
+		return nil, er.ErrInvalidToken
+	}
+	if time.Now().After(expiry.Add(s.leeway + s.accessGrace)) {
+		return nil, er.ErrTokenExpired
+	}
+	return claims, nil
+}
+
+// checkNotRevoked проверяет наличие jti и отсутствие токена в списке отозванных
+func (s *Service) checkNotRevoked(ctx context.Context, claims *models.AccessTokenClaims) error {
+	jti := claims.JTI()
+	if jti == uuid.Nil {
+		return er.ErrInvalidToken
+	}
+	revoked, err := s.repo.IsAccessTokenRevoked(ctx, jti)
+	if err != nil {
+		return fmt.Errorf("failed to check access token revocation: %w", err)
+	}
+	if revoked {
+		return er.ErrInvalidToken
+	}
+	return nil
+}
+
+// revokedUntil срок хранения jti в списке отозванных: токен должен оставаться отозванным,
+// пока его можно предъявить для обновления пары
+func (s *Service) revokedUntil(expiresAt time.Time) time.Time {
+	return expiresAt.Add(s.leeway + s.accessGrace)
+}
+
+// newAccessTokenClaims заполняет поля, общие для access токенов пользователей и клиентов.
+// sub заполняет вызывающий код.
+func (s *Service) newAccessTokenClaims(now time.Time) *models.AccessTokenClaims {
+	return &models.AccessTokenClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID:        uuid.NewString(),
+			Issuer:    s.issuer,
+			Audience:  jwt.ClaimStrings{s.audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTTL)),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
-	signed, err := token.SignedString(s.jwtSecret)
+}
+
+func (s *Service) generateAccessToken(claims *models.AccessTokenClaims) (string, error) {
+	signed, err := s.codec.encode(claims)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign access token: %w", err)
 	}
 	return signed, nil
 }
 
+// signJWT подписывает claims активным ключом keyring
+func signJWT(keys *keyring, claims jwt.Claims) (string, error) {
+	key, err := keys.signing(time.Now())
+	if err != nil {
+		return "", fmt.Errorf("failed to select signing key: %w", err)
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	if key.kid != "" {
+		token.Header["kid"] = key.kid
+	}
+	return token.SignedString(key.private)
+}
+
 func (s *Service) parseAccessToken(tokenStr string) (*models.AccessTokenClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenStr, &models.AccessTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return s.jwtSecret, nil
-	})
+	claims, err := s.codec.decode(tokenStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse access token: %w", err)
 	}
-	claims, ok := token.Claims.(*models.AccessTokenClaims)
-	if !ok || !token.Valid {
-		return nil, er.ErrInvalidToken
+	opts := append(s.claimsOptions(), jwt.WithExpirationRequired())
+	if err := jwt.NewValidator(opts...).Validate(claims); err != nil {
+		return nil, fmt.Errorf("invalid access token claims: %w", err)
 	}
 	return claims, nil
 }
 
+// claimsOptions правила проверки iss, aud, iat и nbf access токена
+func (s *Service) claimsOptions() []jwt.ParserOption {
+	return []jwt.ParserOption{
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(s.audience),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(s.leeway),
+	}
+}
+
+// findRefreshToken находит refresh токен по селектору и сверяет verifier с хешем.
+// Проверку валидности и срока действия выполняет вызывающий код.
+func (s *Service) findRefreshToken(ctx context.Context, refreshTokenRaw string) (*models.RefreshToken, error) {
+	selector, verifier, ok := strings.Cut(refreshTokenRaw, refreshTokenSeparator)
+	if !ok || selector == "" || verifier == "" {
+		return nil, er.ErrInvalidToken
+	}
+	refreshToken, err := s.repo.GetRefreshTokenBySelector(ctx, selector)
+	if err != nil {
+		if errors.Is(err, er.ErrNotFound) {
+			return nil, er.ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(refreshToken.TokenHash), []byte(verifier)) != nil {
+		return nil, er.ErrInvalidToken
+	}
+	return refreshToken, nil
+}
+
 func generateRandomBase64(n int) (string, error) {
 	b := make([]byte, n)
 	_, err := rand.Read(b)
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"auth-service/internal/models"
+	"auth-service/pkg/er"
+)
+
+// Политики при достижении MAX_SESSIONS_PER_USER
+const (
+	SessionLimitEvictOldest = "evict_oldest"
+	SessionLimitReject      = "reject"
+)
+
+func newSessionLimit(cfg Config) (models.SessionLimit, error) {
+	if cfg.MaxSessionsPerUser < 0 {
+		return models.SessionLimit{}, errors.New("max sessions per user must not be negative")
+	}
+	switch cfg.SessionLimitPolicy {
+	case SessionLimitEvictOldest, SessionLimitReject:
+	default:
+		return models.SessionLimit{}, fmt.Errorf("unsupported session limit policy %q", cfg.SessionLimitPolicy)
+	}
+	return models.SessionLimit{
+		Max:         cfg.MaxSessionsPerUser,
+		EvictOldest: cfg.SessionLimitPolicy == SessionLimitEvictOldest,
+	}, nil
+}
+
+// saveRefreshToken сохраняет refresh токен. Преемник сохраняется вместе с ротацией родителя; если родителя
+// успел ротировать параллельный запрос, возвращается er.ErrInvalidToken.
+// Первый токен новой сессии сохраняется с учётом лимита сессий:
+// вытесненные сессии теряют и access токены, а при политике reject возвращается er.ErrTooManySessions.
+func (s *Service) saveRefreshToken(ctx context.Context, rt *models.RefreshToken) error {
+	if rt.ParentID != nil {
+		if err := s.repo.RotateAndInsertRefreshToken(ctx, *rt.ParentID, rt); err != nil {
+			if errors.Is(err, er.ErrNotFound) {
+				return er.ErrInvalidToken
+			}
+			return fmt.Errorf("failed to rotate refresh token: %w", err)
+		}
+		return nil
+	}
+	if s.sessionLimit.Max == 0 {
+		if err := s.repo.CreateRefreshToken(ctx, rt); err != nil {
+			return fmt.Errorf("failed to create refresh token: %w", err)
+		}
+		return nil
+	}
+	evicted, err := s.repo.CreateSession(ctx, rt, s.sessionLimit)
+	if err != nil {
+		if errors.Is(err, er.ErrTooManySessions) {
+			return err
+		}
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	for _, t := range evicted {
+		zap.S().Infof("user %s reached session limit %d, session %s evicted", rt.UserID, s.sessionLimit.Max, t.FamilyID)
+	}
+	return s.revokePairedAccessTokens(ctx, evicted)
+}
+
+// ListSessions возвращает активные сессии пользователя, начиная с самой новой.
+// Сессия — цепочка refresh токенов (family); её текущий токен определяет устройство, IP, срок действия,
+// момент последнего использования и число обновлений, а первый токен цепочки — момент входа.
+// currentSessionID отмечает сессию, из которой сделан запрос.
+func (s *Service) ListSessions(ctx context.Context, userID, currentSessionID uuid.UUID) ([]*Session, error) {
+	active, err := s.repo.GetUserActiveSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active sessions for user %s: %w", userID, err)
+	}
+
+	ips, err := s.repo.GetUserSessionIPs(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session ip history for user %s: %w", userID, err)
+	}
+	history := make(map[uuid.UUID][]*models.SessionIP)
+	for _, a := range active {
+		history[a.Token.FamilyID] = []*models.SessionIP{}
+	}
+	for _, ip := range ips {
+		if _, ok := history[ip.FamilyID]; ok {
+			history[ip.FamilyID] = append(history[ip.FamilyID], ip)
+		}
+	}
+
+	sessions := make([]*Session, 0, len(active))
+	for _, a := range active {
+		t := a.Token
+		sessions = append(sessions, &Session{
+			ID:           t.FamilyID,
+			UserAgent:    t.UserAgent,
+			Device:       sessionDevice(t),
+			IP:           t.IP,
+			ClientID:     t.ClientID,
+			IssuedAt:     a.StartedAt,
+			LastUsedAt:   t.LastUsedAt,
+			RefreshCount: t.RefreshCount,
+			ExpiresAt:    t.ExpiresAt,
+			Current:      t.FamilyID == currentSessionID,
+			IPHistory:    history[t.FamilyID],
+		})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].IssuedAt.After(sessions[j].IssuedAt)
+	})
+	return sessions, nil
+}
+
+// sessionDevice возвращает разобранный User-Agent токена, разбирая его на лету для токенов,
+// выпущенных до появления структурированных колонок
+func sessionDevice(t *models.RefreshToken) models.Device {
+	if t.Device == (models.Device{}) {
+		return parseUserAgent(t.UserAgent)
+	}
+	return t.Device
+}
+
+// RevokeSession завершает одну сессию пользователя и отзывает access токены всех её пар, которые ещё могут быть предъявлены.
+// Если у пользователя нет активной сессии sessionID (в том числе когда она принадлежит другому
+// пользователю), возвращается er.ErrNotFound.
+func (s *Service) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	family, err := s.repo.RevokeTokenFamily(ctx, userID, sessionID, s.liveTokensIssuedAfter())
+	if err != nil {
+		return err
+	}
+	if !hasActiveToken(family) {
+		return er.ErrNotFound
+	}
+	return s.revokePairedAccessTokens(ctx, family)
+}
+
+// RevokeOtherSessions завершает все сессии пользователя, кроме currentSessionID,
+// и возвращает число завершённых активных сессий
+func (s *Service) RevokeOtherSessions(ctx context.Context, userID, currentSessionID uuid.UUID) (int, error) {
+	others, err := s.repo.RevokeUserTokensExcept(ctx, userID, currentSessionID, s.liveTokensIssuedAfter())
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	revoked := make(map[uuid.UUID]bool)
+	for _, t := range others {
+		if t.IsValid && t.ExpiresAt.After(now) {
+			revoked[t.FamilyID] = true
+		}
+	}
+	if err := s.revokePairedAccessTokens(ctx, others); err != nil {
+		return 0, err
+	}
+	return len(revoked), nil
+}
+
+// hasActiveToken сообщает, есть ли среди токенов валидный и не истёкший
+func hasActiveToken(tokens []*models.RefreshToken) bool {
+	now := time.Now()
+	for _, t := range tokens {
+		if t.IsValid && t.ExpiresAt.After(now) {
+			return true
+		}
+	}
+	return false
+}
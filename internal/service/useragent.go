@@ -0,0 +1,88 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mssola/useragent"
+
+	"auth-service/internal/models"
+)
+
+// Политики сравнения User-Agent при обновлении токенов (UA_MATCH_POLICY)
+const (
+	// UserAgentMatchExact требует побайтового совпадения строки User-Agent
+	UserAgentMatchExact = "exact"
+	// UserAgentMatchFamily требует того же браузера, ОС и типа устройства;
+	// версия браузера может расти, но не уменьшаться
+	UserAgentMatchFamily = "family"
+	// UserAgentMatchIgnore не сравнивает User-Agent
+	UserAgentMatchIgnore = "ignore"
+)
+
+const (
+	DeviceTypeDesktop = "desktop"
+	DeviceTypeMobile  = "mobile"
+	DeviceTypeBot     = "bot"
+)
+
+func validateUserAgentMatchPolicy(policy string) error {
+	switch policy {
+	case UserAgentMatchExact, UserAgentMatchFamily, UserAgentMatchIgnore:
+		return nil
+	}
+	return fmt.Errorf("unsupported user agent match policy %q", policy)
+}
+
+// parseUserAgent разбирает User-Agent на браузер, его мажорную версию, ОС и тип устройства
+func parseUserAgent(raw string) models.Device {
+	if strings.TrimSpace(raw) == "" {
+		return models.Device{}
+	}
+	ua := useragent.New(raw)
+	browser, version := ua.Browser()
+	device := models.Device{
+		Browser:      browser,
+		BrowserMajor: majorVersion(version),
+		OS:           ua.OSInfo().Name,
+		Type:         DeviceTypeDesktop,
+	}
+	switch {
+	case ua.Bot():
+		device.Type = DeviceTypeBot
+	case ua.Mobile():
+		device.Type = DeviceTypeMobile
+	}
+	return device
+}
+
+func majorVersion(version string) int {
+	major, _, _ := strings.Cut(version, ".")
+	n, err := strconv.Atoi(major)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// userAgentMatches сравнивает User-Agent запроса на обновление с User-Agent сессии по политике s.uaMatchPolicy
+func (s *Service) userAgentMatches(refreshToken *models.RefreshToken, userAgent string) bool {
+	switch s.uaMatchPolicy {
+	case UserAgentMatchIgnore:
+		return true
+	case UserAgentMatchFamily:
+		stored := sessionDevice(refreshToken)
+		// неразобранный User-Agent сравнивается целиком
+		if stored.Browser == "" {
+			return refreshToken.UserAgent == userAgent
+		}
+		current := parseUserAgent(userAgent)
+		return current.Browser == stored.Browser &&
+			current.OS == stored.OS &&
+			current.Type == stored.Type &&
+			current.BrowserMajor >= stored.BrowserMajor
+	default:
+		return refreshToken.UserAgent == userAgent
+	}
+}
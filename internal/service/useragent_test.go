@@ -0,0 +1,99 @@
+package service
+
+import (
+	"testing"
+
+	"auth-service/internal/models"
+)
+
+const (
+	chrome120 = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.109 Safari/537.36"
+	chrome121 = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/121.0.6167.85 Safari/537.36"
+	chrome119 = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.6045.199 Safari/537.36"
+	chromeMac = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.109 Safari/537.36"
+	firefox   = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0"
+	iphone    = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1"
+	googlebot = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want models.Device
+	}{
+		{chrome120, models.Device{Browser: "Chrome", BrowserMajor: 120, OS: "Windows", Type: DeviceTypeDesktop}},
+		{firefox, models.Device{Browser: "Firefox", BrowserMajor: 121, OS: "Windows", Type: DeviceTypeDesktop}},
+		{iphone, models.Device{Browser: "Safari", BrowserMajor: 17, OS: "iPhone OS", Type: DeviceTypeMobile}},
+		{"", models.Device{}},
+		{"   ", models.Device{}},
+	}
+	for _, tt := range tests {
+		if got := parseUserAgent(tt.raw); got != tt.want {
+			t.Errorf("parseUserAgent(%q) = %+v, want %+v", tt.raw, got, tt.want)
+		}
+	}
+	if got := parseUserAgent(googlebot); got.Type != DeviceTypeBot {
+		t.Errorf("parseUserAgent(googlebot).Type = %q, want %q", got.Type, DeviceTypeBot)
+	}
+}
+
+func TestMajorVersion(t *testing.T) {
+	tests := map[string]int{"120.0.6099.109": 120, "17": 17, "": 0, "x.1": 0, "-1.0": 0}
+	for version, want := range tests {
+		if got := majorVersion(version); got != want {
+			t.Errorf("majorVersion(%q) = %d, want %d", version, got, want)
+		}
+	}
+}
+
+func TestUserAgentMatches(t *testing.T) {
+	session := &models.RefreshToken{UserAgent: chrome120, Device: parseUserAgent(chrome120)}
+	// токен, выпущенный до появления разбора User-Agent: Device пуст и восстанавливается из строки
+	legacy := &models.RefreshToken{UserAgent: chrome120}
+	client := &models.RefreshToken{UserAgent: "custom-client/1.0", Device: parseUserAgent("custom-client/1.0")}
+	// сессия без User-Agent не разбирается и сравнивается целиком
+	anonymous := &models.RefreshToken{}
+
+	tests := []struct {
+		name   string
+		policy string
+		token  *models.RefreshToken
+		ua     string
+		want   bool
+	}{
+		{"exact same", UserAgentMatchExact, session, chrome120, true},
+		{"exact upgrade", UserAgentMatchExact, session, chrome121, false},
+		{"family same", UserAgentMatchFamily, session, chrome120, true},
+		{"family upgrade", UserAgentMatchFamily, session, chrome121, true},
+		{"family downgrade", UserAgentMatchFamily, session, chrome119, false},
+		{"family other os", UserAgentMatchFamily, session, chromeMac, false},
+		{"family other browser", UserAgentMatchFamily, session, firefox, false},
+		{"family other device", UserAgentMatchFamily, session, iphone, false},
+		{"family empty", UserAgentMatchFamily, session, "", false},
+		{"family legacy token", UserAgentMatchFamily, legacy, chrome121, true},
+		{"family client upgrade", UserAgentMatchFamily, client, "custom-client/1.1", true},
+		{"family other client", UserAgentMatchFamily, client, "other-client/1.0", false},
+		{"family anonymous", UserAgentMatchFamily, anonymous, "", true},
+		{"family anonymous to browser", UserAgentMatchFamily, anonymous, chrome120, false},
+		{"ignore", UserAgentMatchIgnore, session, firefox, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{uaMatchPolicy: tt.policy}
+			if got := s.userAgentMatches(tt.token, tt.ua); got != tt.want {
+				t.Fatalf("userAgentMatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateUserAgentMatchPolicy(t *testing.T) {
+	for _, policy := range []string{UserAgentMatchExact, UserAgentMatchFamily, UserAgentMatchIgnore} {
+		if err := validateUserAgentMatchPolicy(policy); err != nil {
+			t.Errorf("validateUserAgentMatchPolicy(%q) = %v", policy, err)
+		}
+	}
+	if err := validateUserAgentMatchPolicy("Family"); err == nil {
+		t.Error("validateUserAgentMatchPolicy must reject unknown policies")
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"auth-service/internal/models"
+	"auth-service/pkg/er"
+)
+
+const (
+	// RoleUser роль, которую получает каждый созданный пользователь
+	RoleUser = "user"
+	// ScopeUsersAdmin разрешает управлять пользователями через /api/admin/users
+	ScopeUsersAdmin = "users:admin"
+)
+
+// CreateUser создаёт пользователя с ролью RoleUser. Если id не передан (uuid.Nil), он генерируется сервером.
+// Возвращает er.ErrAlreadyExists, если пользователь с таким id уже есть.
+func (s *Service) CreateUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	if id == uuid.Nil {
+		id = uuid.New()
+	}
+	user, err := s.repo.CreateUser(ctx, id, RoleUser)
+	if err != nil {
+		if errors.Is(err, er.ErrAlreadyExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	return user, nil
+}
+
+// DisableUser блокирует пользователя и завершает все его сессии
+func (s *Service) DisableUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	user, err := s.setUserDisabled(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.revokeAllUserSessions(ctx, id); err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions of disabled user %s: %w", id, err)
+	}
+	return user, nil
+}
+
+// EnableUser снимает блокировку пользователя. Сессии, завершённые при блокировке, не восстанавливаются.
+func (s *Service) EnableUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return s.setUserDisabled(ctx, id, false)
+}
+
+func (s *Service) setUserDisabled(ctx context.Context, id uuid.UUID, disabled bool) (*models.User, error) {
+	user, err := s.repo.SetUserDisabled(ctx, id, disabled)
+	if err != nil {
+		if errors.Is(err, er.ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	return user, nil
+}
+
+// DeleteUser удаляет пользователя. Access токены его сессий отзываются до удаления,
+// пока ещё известны их jti; refresh токены удаляются вместе с пользователем.
+func (s *Service) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	if err := s.checkUserExists(ctx, id); err != nil {
+		return err
+	}
+	if err := s.revokeAllUserSessions(ctx, id); err != nil {
+		return fmt.Errorf("failed to revoke sessions of user %s: %w", id, err)
+	}
+	if err := s.repo.DeleteUser(ctx, id); err != nil {
+		if errors.Is(err, er.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) checkUserExists(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.repo.GetUserByID(ctx, id); err != nil {
+		if errors.Is(err, er.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to get user by id %s: %w", id, err)
+	}
+	return nil
+}
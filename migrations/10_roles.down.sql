@@ -0,0 +1,4 @@
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS scope;
+
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,21 @@
+-- Роли пользователей и scope, которые они дают в access токене
+CREATE TABLE roles (
+    name VARCHAR(50) PRIMARY KEY,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE user_roles (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(50) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, role)
+);
+
+INSERT INTO roles (name, scopes) VALUES ('user', '{openid,profile,sessions:read,sessions:write}');
+
+INSERT INTO user_roles (user_id, role) SELECT id, 'user' FROM users;
+
+-- Scope, запрошенный OAuth2 клиентом; при обновлении токены не получают scope шире исходного
+ALTER TABLE refresh_tokens ADD COLUMN scope TEXT NOT NULL DEFAULT '';
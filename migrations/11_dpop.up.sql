@@ -0,0 +1,2 @@
+-- Отпечаток (RFC 7638) DPoP ключа, к которому привязана сессия; NULL для bearer сессий
+ALTER TABLE refresh_tokens ADD COLUMN dpop_jkt VARCHAR(64);
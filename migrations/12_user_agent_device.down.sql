@@ -0,0 +1,6 @@
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS ua_device;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS ua_os;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS ua_browser_major;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS ua_browser;
+
+ALTER TABLE refresh_tokens ALTER COLUMN user_agent TYPE VARCHAR(255) USING LEFT(user_agent, 255);
@@ -0,0 +1,8 @@
+-- Длинные строки User-Agent не помещались в VARCHAR(255)
+ALTER TABLE refresh_tokens ALTER COLUMN user_agent TYPE TEXT;
+
+-- Разобранный User-Agent; у токенов, выпущенных до миграции, колонки пусты
+ALTER TABLE refresh_tokens ADD COLUMN ua_browser TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN ua_browser_major INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE refresh_tokens ADD COLUMN ua_os TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN ua_device VARCHAR(16) NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS session_ip_history;
+DROP FUNCTION IF EXISTS session_ip_history_append_only();
+
+ALTER TABLE refresh_tokens
+    DROP COLUMN IF EXISTS refresh_count,
+    DROP COLUMN IF EXISTS last_used_at;
@@ -0,0 +1,39 @@
+ALTER TABLE refresh_tokens
+    ADD COLUMN last_used_at TIMESTAMP,
+    ADD COLUMN refresh_count INTEGER NOT NULL DEFAULT 0;
+
+-- Для существующих токенов момент использования — момент выпуска,
+-- а число обновлений — число предшественников в цепочке
+UPDATE refresh_tokens r SET
+    last_used_at = r.issued_at,
+    refresh_count = (SELECT COUNT(*) FROM refresh_tokens p WHERE p.family_id = r.family_id AND p.issued_at < r.issued_at);
+
+ALTER TABLE refresh_tokens
+    ALTER COLUMN last_used_at SET NOT NULL,
+    ALTER COLUMN last_used_at SET DEFAULT NOW();
+
+-- История адресов сессий: строка на каждую выдачу и обновление токенов, записи не изменяются
+CREATE TABLE session_ip_history (
+    id BIGSERIAL PRIMARY KEY,
+    family_id UUID NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ip VARCHAR(45) NOT NULL,
+    user_agent TEXT NOT NULL,
+    seen_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_session_ip_history_user_id ON session_ip_history(user_id);
+CREATE INDEX idx_session_ip_history_family_id ON session_ip_history(family_id);
+
+CREATE FUNCTION session_ip_history_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'session_ip_history is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER session_ip_history_no_update
+    BEFORE UPDATE ON session_ip_history
+    FOR EACH ROW EXECUTE FUNCTION session_ip_history_append_only();
+
+INSERT INTO session_ip_history (family_id, user_id, ip, user_agent, seen_at)
+SELECT family_id, user_id, ip, user_agent, issued_at FROM refresh_tokens ORDER BY issued_at;
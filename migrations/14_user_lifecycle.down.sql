@@ -0,0 +1,3 @@
+DELETE FROM roles WHERE name = 'admin';
+
+ALTER TABLE users DROP COLUMN IF EXISTS disabled_at;
@@ -0,0 +1,5 @@
+-- Момент блокировки пользователя; NULL для активных пользователей
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP;
+
+-- Роль администратора пользователей; назначается вручную
+INSERT INTO roles (name, scopes) VALUES ('admin', '{users:admin}');
@@ -0,0 +1,9 @@
+-- Учётные данные для входа по паролю; пользователи без строки в таблице входят только через доверенную выдачу
+CREATE TABLE credentials (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    login VARCHAR(254) NOT NULL UNIQUE,
+    -- Хеш Argon2id в формате PHC: $argon2id$v=19$m=...,t=...,p=...$<salt>$<hash>
+    password_hash TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
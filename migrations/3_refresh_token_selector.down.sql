@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_refresh_tokens_selector;
+
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS selector;
@@ -0,0 +1,6 @@
+ALTER TABLE refresh_tokens ADD COLUMN selector VARCHAR(32);
+
+-- Токены старого формата нельзя найти по селектору, поэтому они больше не принимаются
+UPDATE refresh_tokens SET is_valid = false WHERE selector IS NULL;
+
+CREATE UNIQUE INDEX idx_refresh_tokens_selector ON refresh_tokens(selector);
@@ -0,0 +1,11 @@
+ALTER TABLE refresh_tokens
+    ADD COLUMN family_id UUID,
+    ADD COLUMN parent_id INTEGER REFERENCES refresh_tokens(id) ON DELETE SET NULL,
+    ADD COLUMN rotated_at TIMESTAMP;
+
+-- Каждый существующий токен становится отдельной цепочкой
+UPDATE refresh_tokens SET family_id = gen_random_uuid() WHERE family_id IS NULL;
+
+ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
@@ -0,0 +1,2 @@
+-- jti access токена, выпущенного вместе с refresh токеном
+ALTER TABLE refresh_tokens ADD COLUMN access_jti UUID;
@@ -0,0 +1,7 @@
+-- Отозванные до истечения срока действия access токены
+CREATE TABLE revoked_access_tokens (
+    jti UUID PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_revoked_access_tokens_expires_at ON revoked_access_tokens(expires_at);
@@ -0,0 +1,8 @@
+-- OAuth2 клиенты (сервисы), которым выдаются токены без участия пользователя
+CREATE TABLE clients (
+    client_id VARCHAR(100) PRIMARY KEY,
+    secret_hash VARCHAR(100) NOT NULL, -- bcrypt hash
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_authorization_codes_expires_at;
+
+DROP TABLE IF EXISTS authorization_codes;
+
+DELETE FROM clients WHERE secret_hash IS NULL;
+
+ALTER TABLE clients
+    DROP COLUMN IF EXISTS redirect_uris,
+    ALTER COLUMN secret_hash SET NOT NULL;
@@ -0,0 +1,18 @@
+-- Публичные клиенты (SPA, мобильные приложения) не имеют секрета
+ALTER TABLE clients
+    ALTER COLUMN secret_hash DROP NOT NULL,
+    ADD COLUMN redirect_uris TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE TABLE authorization_codes (
+    code_hash VARCHAR(64) PRIMARY KEY, -- sha256 hex
+    client_id VARCHAR(100) NOT NULL REFERENCES clients(client_id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    code_challenge VARCHAR(128) NOT NULL,
+    scope TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_authorization_codes_expires_at ON authorization_codes(expires_at);
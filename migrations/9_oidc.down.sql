@@ -0,0 +1,3 @@
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS client_id;
+
+ALTER TABLE authorization_codes DROP COLUMN IF EXISTS nonce;
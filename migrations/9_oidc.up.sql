@@ -0,0 +1,5 @@
+-- nonce из запроса авторизации возвращается в id_token
+ALTER TABLE authorization_codes ADD COLUMN nonce TEXT NOT NULL DEFAULT '';
+
+-- Клиент, которому выдана сессия через authorization code; используется как aud id_token при обновлении
+ALTER TABLE refresh_tokens ADD COLUMN client_id VARCHAR(100);
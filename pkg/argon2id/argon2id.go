@@ -0,0 +1,120 @@
+package argon2id
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// DefaultSaltLength длина соли в байтах
+	DefaultSaltLength = 16
+	// DefaultKeyLength длина хеша в байтах
+	DefaultKeyLength = 32
+)
+
+var (
+	ErrInvalidHash         = errors.New("invalid argon2id hash")
+	ErrIncompatibleVersion = errors.New("incompatible argon2 version")
+)
+
+// Params параметры Argon2id (RFC 9106). Memory задаётся в KiB.
+type Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Validate проверяет, что параметры допустимы для Argon2id
+func (p Params) Validate() error {
+	if p.Iterations < 1 {
+		return errors.New("argon2id iterations must be at least 1")
+	}
+	if p.Parallelism < 1 {
+		return errors.New("argon2id parallelism must be at least 1")
+	}
+	if p.Memory < 8*uint32(p.Parallelism) {
+		return fmt.Errorf("argon2id memory must be at least %d KiB for parallelism %d", 8*uint32(p.Parallelism), p.Parallelism)
+	}
+	if p.SaltLength < 8 {
+		return errors.New("argon2id salt must be at least 8 bytes")
+	}
+	if p.KeyLength < 16 {
+		return errors.New("argon2id key must be at least 16 bytes")
+	}
+	return nil
+}
+
+// Hash вычисляет хеш пароля со случайной солью и возвращает его в формате PHC:
+// $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<hash>
+func Hash(password string, p Params) (string, error) {
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify сравнивает пароль с хешем в формате PHC за время, не зависящее от совпадения
+func Verify(password, encoded string) (bool, error) {
+	p, salt, key, err := decode(encoded)
+	if err != nil {
+		return false, err
+	}
+	other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// NeedsRehash сообщает, что хеш вычислен с параметрами, отличными от p, и его следует пересчитать
+func NeedsRehash(encoded string, p Params) bool {
+	current, _, _, err := decode(encoded)
+	if err != nil {
+		return true
+	}
+	return current != p
+}
+
+// decode разбирает хеш в формате PHC; длины соли и ключа берутся из самих значений
+func decode(encoded string) (Params, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return Params{}, nil, nil, ErrInvalidHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return Params{}, nil, nil, ErrInvalidHash
+	}
+	if version != argon2.Version {
+		return Params{}, nil, nil, ErrIncompatibleVersion
+	}
+	var p Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return Params{}, nil, nil, ErrInvalidHash
+	}
+	salt, err := base64.RawStdEncoding.Strict().DecodeString(parts[4])
+	if err != nil {
+		return Params{}, nil, nil, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.Strict().DecodeString(parts[5])
+	if err != nil {
+		return Params{}, nil, nil, ErrInvalidHash
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	if p.Validate() != nil {
+		return Params{}, nil, nil, ErrInvalidHash
+	}
+	return p, salt, key, nil
+}
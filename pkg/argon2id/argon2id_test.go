@@ -0,0 +1,115 @@
+package argon2id
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testParams минимальные допустимые параметры, чтобы тесты не тратили время на хеширование
+var testParams = Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: DefaultSaltLength, KeyLength: DefaultKeyLength}
+
+func TestHashVerify(t *testing.T) {
+	encoded, err := Hash("correct horse battery staple", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("unexpected PHC string %q", encoded)
+	}
+
+	ok, err := Verify("correct horse battery staple", encoded)
+	if err != nil || !ok {
+		t.Fatalf("Verify(correct) = %v, %v; want true, nil", ok, err)
+	}
+	ok, err = Verify("correct horse battery stapler", encoded)
+	if err != nil || ok {
+		t.Fatalf("Verify(wrong) = %v, %v; want false, nil", ok, err)
+	}
+
+	other, err := Hash("correct horse battery staple", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == encoded {
+		t.Fatal("hashes of the same password must use different salts")
+	}
+}
+
+func TestVerifyInvalidHash(t *testing.T) {
+	valid, err := Hash("password", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(valid, "$")
+
+	tests := []struct {
+		name    string
+		encoded string
+		want    error
+	}{
+		{"empty", "", ErrInvalidHash},
+		{"bcrypt", "$2a$10$abcdefghijklmnopqrstuu", ErrInvalidHash},
+		{"argon2i", strings.Replace(valid, "$argon2id$", "$argon2i$", 1), ErrInvalidHash},
+		{"old version", strings.Replace(valid, "$v=19$", "$v=16$", 1), ErrIncompatibleVersion},
+		{"bad params", strings.Join([]string{"", parts[1], parts[2], "m=x,t=1,p=1", parts[4], parts[5]}, "$"), ErrInvalidHash},
+		{"zero iterations", strings.Join([]string{"", parts[1], parts[2], "m=64,t=0,p=1", parts[4], parts[5]}, "$"), ErrInvalidHash},
+		{"padded salt", strings.Join([]string{"", parts[1], parts[2], parts[3], parts[4] + "==", parts[5]}, "$"), ErrInvalidHash},
+		{"short key", strings.Join([]string{"", parts[1], parts[2], parts[3], parts[4], "AAAA"}, "$"), ErrInvalidHash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := Verify("password", tt.encoded)
+			if ok || !errors.Is(err, tt.want) {
+				t.Fatalf("Verify() = %v, %v; want false, %v", ok, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	encoded, err := Hash("password", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if NeedsRehash(encoded, testParams) {
+		t.Fatal("hash with current params must not need rehash")
+	}
+
+	stronger := testParams
+	stronger.Iterations = 2
+	if !NeedsRehash(encoded, stronger) {
+		t.Fatal("hash with fewer iterations must need rehash")
+	}
+	longer := testParams
+	longer.KeyLength = 64
+	if !NeedsRehash(encoded, longer) {
+		t.Fatal("hash with shorter key must need rehash")
+	}
+	if !NeedsRehash("not a hash", testParams) {
+		t.Fatal("invalid hash must need rehash")
+	}
+}
+
+func TestParamsValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		p     Params
+		valid bool
+	}{
+		{"defaults", Params{Memory: 65536, Iterations: 3, Parallelism: 4, SaltLength: 16, KeyLength: 32}, true},
+		{"minimal", testParams, true},
+		{"no iterations", Params{Memory: 64, Parallelism: 1, SaltLength: 16, KeyLength: 32}, false},
+		{"no parallelism", Params{Memory: 64, Iterations: 1, SaltLength: 16, KeyLength: 32}, false},
+		{"memory below 8*p", Params{Memory: 31, Iterations: 1, Parallelism: 4, SaltLength: 16, KeyLength: 32}, false},
+		{"short salt", Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 4, KeyLength: 32}, false},
+		{"short key", Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 8}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.p.Validate(); (err == nil) != tt.valid {
+				t.Fatalf("Validate() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
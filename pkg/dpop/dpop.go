@@ -0,0 +1,157 @@
+package dpop
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"auth-service/pkg/jwk"
+)
+
+// HeaderName заголовок, в котором клиент передаёт DPoP proof
+const HeaderName = "DPoP"
+
+// proofType значение typ в заголовке DPoP proof
+const proofType = "dpop+jwt"
+
+// SigningAlgs асимметричные алгоритмы, которыми может быть подписан proof
+var SigningAlgs = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+var (
+	ErrInvalidProof = errors.New("invalid dpop proof")
+	ErrReplay       = errors.New("dpop proof replayed")
+)
+
+// Claims claims DPoP proof (RFC 9449, 4.2)
+type Claims struct {
+	Htm   string `json:"htm"`
+	Htu   string `json:"htu"`
+	Ath   string `json:"ath,omitempty"`
+	Nonce string `json:"nonce,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Request параметры запроса, к которому относится proof
+type Request struct {
+	Method string
+	URL    string
+	// AccessToken предъявленный вместе с proof access токен; пусто при выдаче токенов
+	AccessToken string
+}
+
+// Proof проверенный DPoP proof
+type Proof struct {
+	JTI string
+	// JKT SHA-256 отпечаток публичного ключа proof по RFC 7638, значение cnf.jkt токена
+	JKT      string
+	IssuedAt time.Time
+}
+
+// Verify проверяет подпись proof ключом из его заголовка, htm, htu, ath и возраст iat.
+// Повторное использование jti проверяет вызывающий код, например через ReplayCache.
+func Verify(proof string, req Request, now time.Time, maxAge, leeway time.Duration) (*Proof, error) {
+	var key *jwk.Key
+	token, err := jwt.ParseWithClaims(proof, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		if typ, _ := token.Header["typ"].(string); typ != proofType {
+			return nil, fmt.Errorf("unexpected typ %q", token.Header["typ"])
+		}
+		raw, ok := token.Header["jwk"].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("jwk header is required")
+		}
+		if _, private := raw["d"]; private {
+			return nil, errors.New("jwk header must not contain a private key")
+		}
+		data, err := json.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+		key = &jwk.Key{}
+		if err := json.Unmarshal(data, key); err != nil {
+			return nil, err
+		}
+		return key.PublicKey()
+	}, jwt.WithValidMethods(SigningAlgs), jwt.WithoutClaimsValidation())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidProof
+	}
+
+	if claims.ID == "" || claims.IssuedAt == nil {
+		return nil, fmt.Errorf("%w: jti and iat are required", ErrInvalidProof)
+	}
+	if claims.Htm != req.Method {
+		return nil, fmt.Errorf("%w: htm %q does not match %s", ErrInvalidProof, claims.Htm, req.Method)
+	}
+	if stripQuery(claims.Htu) != stripQuery(req.URL) {
+		return nil, fmt.Errorf("%w: htu %q does not match %s", ErrInvalidProof, claims.Htu, req.URL)
+	}
+	iat := claims.IssuedAt.Time
+	if iat.After(now.Add(leeway)) || iat.Before(now.Add(-maxAge-leeway)) {
+		return nil, fmt.Errorf("%w: iat is outside of the accepted window", ErrInvalidProof)
+	}
+	if req.AccessToken != "" {
+		sum := sha256.Sum256([]byte(req.AccessToken))
+		if claims.Ath != base64.RawURLEncoding.EncodeToString(sum[:]) {
+			return nil, fmt.Errorf("%w: ath does not match access token", ErrInvalidProof)
+		}
+	}
+
+	jkt, err := key.Thumbprint()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	return &Proof{JTI: claims.ID, JKT: jkt, IssuedAt: iat}, nil
+}
+
+func stripQuery(u string) string {
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		return u[:i]
+	}
+	return u
+}
+
+// ReplayCache хранит jti принятых proof, пока их iat не выйдет из допустимого окна.
+// Кеш локален для процесса: при нескольких экземплярах сервиса proof нужно направлять на один экземпляр
+// или заменить кеш общим хранилищем.
+type ReplayCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastPurge time.Time
+}
+
+// purgeInterval как часто из кеша удаляются записи с истёкшим сроком
+const purgeInterval = time.Minute
+
+func NewReplayCache() *ReplayCache {
+	return &ReplayCache{seen: make(map[string]time.Time)}
+}
+
+// Use запоминает id до момента until; возвращает ErrReplay, если id уже использовался и ещё не истёк
+func (c *ReplayCache) Use(id string, until, now time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.lastPurge) > purgeInterval {
+		for k, exp := range c.seen {
+			if exp.Before(now) {
+				delete(c.seen, k)
+			}
+		}
+		c.lastPurge = now
+	}
+	if exp, ok := c.seen[id]; ok && !exp.Before(now) {
+		return ErrReplay
+	}
+	c.seen[id] = until
+	return nil
+}
@@ -0,0 +1,197 @@
+package dpop
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"auth-service/pkg/jwk"
+)
+
+const (
+	testMethod = "POST"
+	testURL    = "https://auth.example.com/api/tokens/refresh"
+	maxAge     = time.Minute
+	leeway     = 5 * time.Second
+)
+
+type proofKey struct {
+	private crypto.Signer
+	jwk     map[string]interface{}
+	jkt     string
+}
+
+func newProofKey(t *testing.T) *proofKey {
+	t.Helper()
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.FromPublicKey(private.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	jkt, err := key.Thumbprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(key)
+	var header map[string]interface{}
+	if err := json.Unmarshal(data, &header); err != nil {
+		t.Fatal(err)
+	}
+	return &proofKey{private: private, jwk: header, jkt: jkt}
+}
+
+// sign подписывает proof; edit позволяет испортить заголовок перед подписью
+func (k *proofKey) sign(t *testing.T, claims Claims, edit func(token *jwt.Token)) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = proofType
+	token.Header["jwk"] = k.jwk
+	if edit != nil {
+		edit(token)
+	}
+	proof, err := token.SignedString(k.private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proof
+}
+
+func validClaims(now time.Time) Claims {
+	return Claims{
+		Htm: testMethod,
+		Htu: testURL,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       "e1j3V_bKic8-LAEB",
+			IssuedAt: jwt.NewNumericDate(now),
+		},
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	key := newProofKey(t)
+	proof := key.sign(t, validClaims(now), nil)
+
+	got, err := Verify(proof, Request{Method: testMethod, URL: testURL + "?x=1"}, now, maxAge, leeway)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.JKT != key.jkt || got.JTI != "e1j3V_bKic8-LAEB" || !got.IssuedAt.Equal(now.Truncate(time.Second)) {
+		t.Fatalf("Verify() = %+v, want jkt %s", got, key.jkt)
+	}
+}
+
+func TestVerifyAccessTokenHash(t *testing.T) {
+	now := time.Now()
+	key := newProofKey(t)
+	accessToken := "eyJhbGciOiJFUzI1NiJ9.e30.sig"
+	sum := sha256.Sum256([]byte(accessToken))
+	claims := validClaims(now)
+	claims.Ath = base64.RawURLEncoding.EncodeToString(sum[:])
+	proof := key.sign(t, claims, nil)
+
+	if _, err := Verify(proof, Request{Method: testMethod, URL: testURL, AccessToken: accessToken}, now, maxAge, leeway); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(proof, Request{Method: testMethod, URL: testURL, AccessToken: accessToken + "x"}, now, maxAge, leeway); !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("ath of another token: error = %v, want %v", err, ErrInvalidProof)
+	}
+	withoutAth := key.sign(t, validClaims(now), nil)
+	if _, err := Verify(withoutAth, Request{Method: testMethod, URL: testURL, AccessToken: accessToken}, now, maxAge, leeway); !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("missing ath: error = %v, want %v", err, ErrInvalidProof)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	now := time.Now()
+	key := newProofKey(t)
+	other := newProofKey(t)
+	req := Request{Method: testMethod, URL: testURL}
+
+	withClaims := func(edit func(c *Claims)) string {
+		c := validClaims(now)
+		edit(&c)
+		return key.sign(t, c, nil)
+	}
+	withHeader := func(edit func(token *jwt.Token)) string {
+		return key.sign(t, validClaims(now), edit)
+	}
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims(now))
+	hmac.Header["typ"] = proofType
+	hmac.Header["jwk"] = map[string]interface{}{"kty": "oct", "k": "c2VjcmV0"}
+	hmacProof, err := hmac.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		proof string
+	}{
+		{"not a jwt", "proof"},
+		{"wrong typ", withHeader(func(token *jwt.Token) { token.Header["typ"] = "JWT" })},
+		{"no jwk", withHeader(func(token *jwt.Token) { delete(token.Header, "jwk") })},
+		{"jwk of another key", withHeader(func(token *jwt.Token) { token.Header["jwk"] = other.jwk })},
+		{"private jwk", withHeader(func(token *jwt.Token) {
+			private := map[string]interface{}{"d": "AAAA"}
+			for k, v := range key.jwk {
+				private[k] = v
+			}
+			token.Header["jwk"] = private
+		})},
+		{"symmetric alg", hmacProof},
+		{"no jti", withClaims(func(c *Claims) { c.ID = "" })},
+		{"no iat", withClaims(func(c *Claims) { c.IssuedAt = nil })},
+		{"wrong htm", withClaims(func(c *Claims) { c.Htm = "GET" })},
+		{"wrong htu", withClaims(func(c *Claims) { c.Htu = "https://auth.example.com/api/tokens/other" })},
+		{"iat too old", withClaims(func(c *Claims) { c.IssuedAt = jwt.NewNumericDate(now.Add(-maxAge - leeway - time.Second)) })},
+		{"iat in future", withClaims(func(c *Claims) { c.IssuedAt = jwt.NewNumericDate(now.Add(leeway + 2*time.Second)) })},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Verify(tt.proof, req, now, maxAge, leeway); !errors.Is(err, ErrInvalidProof) {
+				t.Fatalf("Verify() error = %v, want %v", err, ErrInvalidProof)
+			}
+		})
+	}
+}
+
+func TestReplayCache(t *testing.T) {
+	c := NewReplayCache()
+	now := time.Now()
+	until := now.Add(maxAge)
+
+	if err := c.Use("jti-1", until, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Use("jti-1", until, now.Add(time.Second)); !errors.Is(err, ErrReplay) {
+		t.Fatalf("second use: error = %v, want %v", err, ErrReplay)
+	}
+	if err := c.Use("jti-2", until, now); err != nil {
+		t.Fatal(err)
+	}
+	// после until jti снова принимается: proof с таким iat всё равно отклонит Verify
+	if err := c.Use("jti-1", until, until.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	later := now.Add(purgeInterval + maxAge + time.Second)
+	if err := c.Use("jti-3", later.Add(maxAge), later); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.seen["jti-2"]; ok {
+		t.Fatal("expired jti must be purged")
+	}
+}
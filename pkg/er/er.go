@@ -7,4 +7,17 @@ var (
 	ErrInvalidToken      = errors.New("invalid token")
 	ErrUserAgentMismatch = errors.New("user agent mismatch")
 	ErrTokenExpired      = errors.New("token expired")
+	ErrTokenReuse        = errors.New("refresh token reuse detected")
+	ErrInvalidClient     = errors.New("invalid client credentials")
+	ErrInvalidScope      = errors.New("invalid scope")
+	ErrInvalidGrant      = errors.New("invalid grant")
+	ErrInvalidRedirect   = errors.New("invalid redirect uri")
+	ErrInvalidDPoPProof  = errors.New("invalid dpop proof")
+	ErrTooManySessions   = errors.New("too many active sessions")
+	ErrUserDisabled      = errors.New("user is disabled")
+	ErrAlreadyExists     = errors.New("already exists")
+	ErrInvalidLogin      = errors.New("invalid login")
+	ErrInvalidPassword   = errors.New("invalid login or password")
+	ErrWeakPassword      = errors.New("password does not meet policy")
+	ErrServerBusy        = errors.New("server is busy, try again later")
 )
@@ -0,0 +1,181 @@
+package jwk
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var ErrUnsupportedKey = errors.New("unsupported key type")
+
+// Key публичный ключ в формате JSON Web Key (RFC 7517)
+type Key struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC и OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// Set набор ключей, отдаваемый на /.well-known/jwks.json
+type Set struct {
+	Keys []Key `json:"keys"`
+}
+
+// FromPublicKey строит JWK по публичному ключу RSA, ECDSA или Ed25519
+func FromPublicKey(pub crypto.PublicKey) (*Key, error) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return &Key{
+			Kty: "RSA",
+			N:   encode(k.N.Bytes()),
+			E:   encode(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		return &Key{
+			Kty: "EC",
+			Crv: k.Curve.Params().Name,
+			X:   encode(k.X.FillBytes(make([]byte, size))),
+			Y:   encode(k.Y.FillBytes(make([]byte, size))),
+		}, nil
+	case ed25519.PublicKey:
+		return &Key{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   encode(k),
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, pub)
+	}
+}
+
+// PublicKey восстанавливает публичный ключ из JWK
+func (k *Key) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rsa modulus: %w", err)
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rsa exponent: %w", err)
+		}
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid rsa key parameters")
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		curve, err := curveByName(k.Crv)
+		if err != nil {
+			return nil, err
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ec x coordinate: %w", err)
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ec y coordinate: %w", err)
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("ec point is not on curve %s", k.Crv)
+		}
+		return pub, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: okp curve %s", ErrUnsupportedKey, k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ed25519 key: %w", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 key size: %d", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("%w: kty %s", ErrUnsupportedKey, k.Kty)
+	}
+}
+
+// Thumbprint вычисляет SHA-256 отпечаток ключа по RFC 7638
+func (k *Key) Thumbprint() (string, error) {
+	// Порядок полей важен: обязательные члены в лексикографическом порядке
+	var members interface{}
+	switch k.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{k.E, k.Kty, k.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{k.Crv, k.Kty, k.X, k.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{k.Crv, k.Kty, k.X}
+	default:
+		return "", fmt.Errorf("%w: kty %s", ErrUnsupportedKey, k.Kty)
+	}
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal jwk members: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return encode(sum[:]), nil
+}
+
+func curveByName(name string) (elliptic.Curve, error) {
+	switch name {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("%w: ec curve %s", ErrUnsupportedKey, name)
+	}
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
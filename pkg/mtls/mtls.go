@@ -0,0 +1,23 @@
+package mtls
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"net/http"
+)
+
+// Thumbprint SHA-256 отпечаток сертификата в DER, значение cnf.x5t#S256 (RFC 8705, 3.1)
+func Thumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// PeerThumbprint возвращает отпечаток клиентского сертификата запроса.
+// Учитывается только сертификат, цепочка которого проверена TLS слоем; без него возвращается пустая строка.
+func PeerThumbprint(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	return Thumbprint(r.TLS.PeerCertificates[0])
+}
@@ -0,0 +1,98 @@
+package paseto
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// HeaderV4Public заголовок токенов PASETO v4.public
+const HeaderV4Public = "v4.public."
+
+var (
+	ErrInvalidToken     = errors.New("invalid paseto token")
+	ErrInvalidSignature = errors.New("invalid paseto signature")
+)
+
+// SignV4Public подписывает payload ключом Ed25519 и возвращает токен v4.public.
+// Footer не шифруется, но защищён подписью; implicit assertion не используется.
+func SignV4Public(key ed25519.PrivateKey, payload, footer []byte) (string, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return "", errors.New("invalid ed25519 private key size")
+	}
+	sig := ed25519.Sign(key, pae([]byte(HeaderV4Public), payload, footer, nil))
+
+	body := make([]byte, 0, len(payload)+len(sig))
+	body = append(body, payload...)
+	body = append(body, sig...)
+	token := HeaderV4Public + base64.RawURLEncoding.EncodeToString(body)
+	if len(footer) > 0 {
+		token += "." + base64.RawURLEncoding.EncodeToString(footer)
+	}
+	return token, nil
+}
+
+// Footer возвращает footer токена без проверки подписи, например чтобы выбрать ключ по kid
+func Footer(token string) ([]byte, error) {
+	_, footer, err := split(token)
+	return footer, err
+}
+
+// VerifyV4Public проверяет подпись токена v4.public и возвращает payload и footer
+func VerifyV4Public(key ed25519.PublicKey, token string) ([]byte, []byte, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, nil, errors.New("invalid ed25519 public key size")
+	}
+	body, footer, err := split(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(body) < ed25519.SignatureSize {
+		return nil, nil, ErrInvalidToken
+	}
+	payload := body[:len(body)-ed25519.SignatureSize]
+	sig := body[len(body)-ed25519.SignatureSize:]
+	if !ed25519.Verify(key, pae([]byte(HeaderV4Public), payload, footer, nil), sig) {
+		return nil, nil, ErrInvalidSignature
+	}
+	return payload, footer, nil
+}
+
+func split(token string) ([]byte, []byte, error) {
+	if !strings.HasPrefix(token, HeaderV4Public) {
+		return nil, nil, ErrInvalidToken
+	}
+	parts := strings.Split(token[len(HeaderV4Public):], ".")
+	if len(parts) > 2 {
+		return nil, nil, ErrInvalidToken
+	}
+	body, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+	var footer []byte
+	if len(parts) == 2 {
+		if footer, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+			return nil, nil, ErrInvalidToken
+		}
+	}
+	return body, footer, nil
+}
+
+// pae Pre-Authentication Encoding: число частей и длина каждой части в виде LE64 перед её содержимым
+func pae(pieces ...[]byte) []byte {
+	out := le64(len(pieces))
+	for _, p := range pieces {
+		out = append(out, le64(len(p))...)
+		out = append(out, p...)
+	}
+	return out
+}
+
+func le64(n int) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(n)&^(1<<63))
+	return b
+}
@@ -0,0 +1,134 @@
+package paseto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSignV4PublicLayout(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte(`{"data":"this is a signed message","exp":"2022-01-01T00:00:00+00:00"}`)
+	footer := []byte(`{"kid":"zVhMiPBP9fRf2snEcT7gFTioeA9COcSy9HTnB3o8x4rZ"}`)
+	token, err := SignV4Public(priv, payload, footer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// v4.public.<base64url(payload || sig)>.<base64url(footer)>, подпись над PAE(h, m, f, i) с пустым i
+	parts := strings.Split(strings.TrimPrefix(token, HeaderV4Public), ".")
+	if !strings.HasPrefix(token, HeaderV4Public) || len(parts) != 2 {
+		t.Fatalf("unexpected token layout: %s", token)
+	}
+	body, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(body[:len(body)-ed25519.SignatureSize], payload) {
+		t.Fatalf("payload is not stored in clear: %s", body)
+	}
+	if got, _ := base64.RawURLEncoding.DecodeString(parts[1]); !bytes.Equal(got, footer) {
+		t.Fatalf("footer = %s, want %s", got, footer)
+	}
+	message := pae([]byte(HeaderV4Public), payload, footer, []byte{})
+	if !ed25519.Verify(pub, message, body[len(body)-ed25519.SignatureSize:]) {
+		t.Fatal("signature does not cover PAE of header, payload, footer and implicit assertion")
+	}
+
+	gotPayload, gotFooter, err := VerifyV4Public(pub, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotPayload, payload) || !bytes.Equal(gotFooter, footer) {
+		t.Fatalf("VerifyV4Public() = %s, %s", gotPayload, gotFooter)
+	}
+	if f, err := Footer(token); err != nil || !bytes.Equal(f, footer) {
+		t.Fatalf("Footer() = %s, %v", f, err)
+	}
+}
+
+func TestVerifyV4PublicRejectsTampering(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := SignV4Public(priv, []byte(`{"sub":"a"}`), []byte(`{"kid":"k1"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherFooter, err := SignV4Public(priv, []byte(`{"sub":"a"}`), []byte(`{"kid":"k2"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapped := token[:strings.LastIndex(token, ".")] + otherFooter[strings.LastIndex(otherFooter, "."):]
+
+	tests := []struct {
+		name  string
+		key   ed25519.PublicKey
+		token string
+		want  error
+	}{
+		{"other key", otherPub, token, ErrInvalidSignature},
+		{"swapped footer", pub, swapped, ErrInvalidSignature},
+		{"dropped footer", pub, token[:strings.LastIndex(token, ".")], ErrInvalidSignature},
+		{"v2 header", pub, strings.Replace(token, "v4.", "v2.", 1), ErrInvalidToken},
+		{"local purpose", pub, strings.Replace(token, ".public.", ".local.", 1), ErrInvalidToken},
+		{"extra part", pub, token + ".x", ErrInvalidToken},
+		{"bad base64", pub, HeaderV4Public + "!!!", ErrInvalidToken},
+		{"short body", pub, HeaderV4Public + "AAAA", ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := VerifyV4Public(tt.key, tt.token); !errors.Is(err, tt.want) {
+				t.Fatalf("VerifyV4Public() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFooterWithoutFooter(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := SignV4Public(priv, []byte(`{}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(token, ".") != 2 {
+		t.Fatalf("token without footer must have two dots: %s", token)
+	}
+	footer, err := Footer(token)
+	if err != nil || footer != nil {
+		t.Fatalf("Footer() = %q, %v; want nil, nil", footer, err)
+	}
+}
+
+func TestPAE(t *testing.T) {
+	// примеры из описания PAE в спецификации PASETO
+	tests := []struct {
+		pieces [][]byte
+		want   string
+	}{
+		{nil, "0000000000000000"},
+		{[][]byte{{}}, "01000000000000000000000000000000"},
+		{[][]byte{[]byte("test")}, "0100000000000000040000000000000074657374"},
+	}
+	for _, tt := range tests {
+		want, _ := hex.DecodeString(tt.want)
+		if got := pae(tt.pieces...); !bytes.Equal(got, want) {
+			t.Errorf("pae(%q) = %x, want %s", tt.pieces, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+#!/bin/sh
+# Генерирует локальный CA, серверный сертификат для localhost и клиентский сертификат
+# для проверки mTLS и привязанных к сертификату токенов (RFC 8705).
+#
+# Использование: scripts/gen-mtls-certs.sh [каталог] [CN клиента]
+set -eu
+
+OUT="${1:-certs}"
+CLIENT_CN="${2:-billing-worker}"
+DAYS=365
+
+mkdir -p "$OUT"
+cd "$OUT"
+
+# CA
+openssl ecparam -name prime256v1 -genkey -noout -out ca.key
+openssl req -x509 -new -key ca.key -sha256 -days "$DAYS" -subj "/CN=auth-service local CA" -out ca.crt
+
+# Сервер
+openssl ecparam -name prime256v1 -genkey -noout -out server.key
+openssl req -new -key server.key -subj "/CN=localhost" -out server.csr
+cat > server.ext <<EXT
+basicConstraints=CA:FALSE
+keyUsage=digitalSignature
+extendedKeyUsage=serverAuth
+subjectAltName=DNS:localhost,IP:127.0.0.1
+EXT
+openssl x509 -req -in server.csr -CA ca.crt -CAkey ca.key -CAcreateserial -sha256 -days "$DAYS" -extfile server.ext -out server.crt
+
+# Клиент
+openssl ecparam -name prime256v1 -genkey -noout -out client.key
+openssl req -new -key client.key -subj "/CN=$CLIENT_CN" -out client.csr
+cat > client.ext <<EXT
+basicConstraints=CA:FALSE
+keyUsage=digitalSignature
+extendedKeyUsage=clientAuth
+EXT
+openssl x509 -req -in client.csr -CA ca.crt -CAkey ca.key -CAcreateserial -sha256 -days "$DAYS" -extfile client.ext -out client.crt
+
+rm -f server.csr client.csr server.ext client.ext ca.srl
+
+# Отпечаток клиентского сертификата — ожидаемое значение cnf.x5t#S256
+printf 'client x5t#S256: '
+openssl x509 -in client.crt -outform der | openssl dgst -sha256 -binary | openssl base64 -A | tr '+/' '-_' | tr -d '='
+echo